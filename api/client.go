@@ -6,12 +6,16 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"time"
 )
 
-type Client struct{}
+type Client struct {
+	httpClient *http.Client
+}
 
-func NewClient() *Client {
-	return &Client{}
+// NewClient creates a client whose requests fail after the timeout, 0 for no timeout
+func NewClient(timeout time.Duration) *Client {
+	return &Client{httpClient: &http.Client{Timeout: timeout}}
 }
 
 func (c *Client) Post(url string, requestBody interface{}) ([]byte, error) {
@@ -20,7 +24,7 @@ func (c *Client) Post(url string, requestBody interface{}) ([]byte, error) {
 		return nil, err
 	}
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(body))
+	resp, err := c.httpClient.Post(url, "application/json", bytes.NewBuffer(body))
 	if err != nil {
 		return nil, err
 	}
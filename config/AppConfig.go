@@ -2,6 +2,7 @@ package config
 
 import (
 	"flag"
+	"fmt"
 
 	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
 	sdpS3 "gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/s3"
@@ -15,6 +16,7 @@ type AppConfig struct {
 	BrokerAddress       *string
 	RestAPIAddress      *string
 	KafkaLocationTopic  *string
+	TelemetrySinks      *string
 	KafkaGwAddress      *string
 	KeycloakTokenUrl    *string
 	GetHealthPath       *string
@@ -35,6 +37,12 @@ type AppConfig struct {
 	AllDroneServersPath *string
 	VideoFeedPath       *string
 	DroneSpeed          *int
+	VehicleSpeed        *int
+	Acceleration        *float64
+	ClimbRate           *float64
+	SimTick             *float64
+	SimSpeed            *float64
+	LocationInterval    *float64
 	Altitude            *int
 	Temperature         *int
 	SignalStrength      *string
@@ -45,10 +53,63 @@ type AppConfig struct {
 	SimulateRealH3D     *bool
 	LogLevel            *string
 	S3Config            sdpS3.S3Config
+	AssetSource         *string
+	AssetDir            *string
+	S3Bucket            *string
+	ResourcesFile       *string
+	ScenarioFile        *string
+	Seed                *int64
 	NoFlyZoneEndPoint   *string
 	GetRoutePath        *string
 	DispatchTime        *int
 	ClearanceTime       *int
+
+	// Telemetry delivery
+	TelemetryQueueSize     *int
+	TelemetryMaxRetries    *int
+	TelemetryRetryBackoff  *float64
+	TelemetryBatchSize     *int
+	TelemetryBatchInterval *float64
+	RestBatchLocationPath  *string
+	RestBatchStatusPath    *string
+
+	// Routing
+	AvoidanceBuffer *float64
+
+	// No-fly zones
+	ZoneRefreshInterval *float64
+	ZonePageSize        *int
+	ZonesFile           *string
+	GeofencePolicy      *string
+
+	// Battery
+	BatteryHoverFactor *float64
+	BatteryClimbFactor *float64
+	DroneMass          *float64
+	Payload            *float64
+	WindSpeed          *float64
+	WindDirection      *float64
+	BatteryChargeRate  *float64
+	BatteryChargeTaper *float64
+	BatteryRtlLevel    *float64
+	BatteryLandLevel   *float64
+
+	// Link
+	LinkLossTimeout   *float64
+	LinkFailsafe      *string
+	LinkCommandPolicy *string
+	LinkBufferSize    *int
+	LinkDegradedLoss  *float64
+
+	// Sensors
+	SensorModel       *string
+	GpsCep            *float64
+	GpsDrift          *float64
+	GpsMultipathRate  *float64
+	GpsMultipathError *float64
+	BaroNoise         *float64
+	HeadingJitter     *float64
+	TemperatureRise   *float64
 }
 
 var appConfig AppConfig
@@ -62,6 +123,7 @@ func Load() {
 		BrokerAddress:       flag.String("broker-address", "localhost:9092", "Broker Address"),
 		RestAPIAddress:      flag.String("drone-connector-url", "http://127.0.0.1:8077/drone-connector/v0", "Url of Drone Connector"),
 		KafkaLocationTopic:  flag.String("kafka-location-topic", "RmsResourceLocation", "Kakfa Location Topic"),
+		TelemetrySinks:      flag.String("telemetry-sinks", "rest", "Comma separated sinks of locations and drone statuses: rest, kafka"),
 		KafkaGwAddress:      flag.String("kafka-gw-address", "https://pilot.sdpcore.apps.thalesdigital.io/rms/v0", "Kafka Gateway Address"),
 		KeycloakTokenUrl:    flag.String("keycloak-token-url", "http://keycloak-http.authentication/auth/realms/sdp/protocol/openid-connect/token", "Keycloak Token Url"),
 		GetHealthPath:       flag.String("get-health-path", "/health", "Get Health Path"),
@@ -82,10 +144,16 @@ func Load() {
 		AllDroneServersPath: flag.String("all-drones-servers-path", "/dbxs", "All Drones Servers Path"),
 		VideoFeedPath:       flag.String("video-feed-path", "/dbx/{dbx_id}/video", "Video Feed Path"),
 		DroneSpeed:          flag.Int("drone-speed", 67, "Drone speed in miles per hour"),
+		VehicleSpeed:        flag.Int("vehicle-speed", 40, "Ground vehicle speed in miles per hour"),
+		Acceleration:        flag.Float64("acceleration", 2, "Horizontal acceleration in meters per second squared"),
+		ClimbRate:           flag.Float64("climb-rate", 3, "Drone climb and descent rate in meters per second"),
+		SimTick:             flag.Float64("sim-tick", 1, "Movement simulation tick in seconds"),
+		SimSpeed:            flag.Float64("sim-speed", 1, "Initial speed of the simulation clock, as a multiple of real time"),
+		LocationInterval:    flag.Float64("location-interval", 5, "Interval between two published locations of a moving resource in seconds"),
 		Altitude:            flag.Int("altitude", 400, "Drone altitude in feet"),
 		Temperature:         flag.Int("temperature", 31, "Temperature in degrees Celsius"),
 		SignalStrength:      flag.String("signal-strength", "Excellent", "Signal strength of drone"),
-		BatteryLife:         flag.Float64("battery-life", 30, "Battery life in minutes"),
+		BatteryLife:         flag.Float64("battery-life", 30, "Battery life in minutes at cruise speed in level flight, without wind nor payload"),
 		StartingLat:         flag.Float64("starting-lat", 1.333558, "Starting laitude of Drone"),
 		StartingLong:        flag.Float64("starting-long", 103.816614, "Starting longitude of Drone"),
 		DroneIds:            flag.String("drone-ids", "605d5aa3c9f9e6b0e44a2925,60501d53f576cd66a4f2b,60501d53f576cd66a42c,6051b9c144811f30c5902ee6", "Drone Ids to Emulate separated by ,"),
@@ -99,15 +167,85 @@ func Load() {
 			DisableSSL:      flag.Bool("s3-disable-ssl", true, "True if SSL should be disable when connecting to S3 storage"),
 			ForcePathStyle:  flag.Bool("s3-force-path-style", true, "True to force path style URL when using S3 APIs"),
 		},
+		AssetSource:       flag.String("asset-source", "s3", "Source of resources and simulation files: s3, local or embedded"),
+		AssetDir:          flag.String("asset-dir", "./assets", "Directory of resources and simulation files when asset-source is local"),
+		S3Bucket:          flag.String("s3-bucket", "sdp-rms-external-simulator", "S3 bucket of resources and simulation files"),
+		ResourcesFile:     flag.String("resources-file", "pilot_resources.json", "Name of the resources file"),
+		ScenarioFile:      flag.String("scenario-file", "scenario.json", "Name of the optional scenario file"),
+		Seed:              flag.Int64("seed", 0, "Seed of the random values, 0 to use the seed of the scenario or a time based seed"),
 		NoFlyZoneEndPoint: flag.String("no-fly-zones-url", "https://pilot.sdpcore.apps.thalesdigital.io/custom_entity/v0/internal/instances/search", "Url for No Fly Zones"),
 		GetRoutePath:      flag.String("get-route-path", "/route", "Get Route Path"),
 		DispatchTime:      flag.Int("dispatchTime", 60, "Dispatch time in seconds"),
-		ClearanceTime:     flag.Int("clearanceTime", 300, "Clearance time in seconds"),
+		ClearanceTime:     flag.Int("clearanceTime", 300, "Clearance time in seconds, until times to clearance are observed"),
+
+		TelemetryQueueSize:     flag.Int("telemetry-queue-size", 1000, "Maximum number of events waiting for delivery per telemetry sink, newer events are dropped beyond"),
+		TelemetryMaxRetries:    flag.Int("telemetry-max-retries", 5, "Maximum number of retries of a telemetry delivery on connection errors and 5xx responses"),
+		TelemetryRetryBackoff:  flag.Float64("telemetry-retry-backoff", 0.5, "Initial wait before retrying a telemetry delivery in seconds, doubled on each retry"),
+		TelemetryBatchSize:     flag.Int("telemetry-batch-size", 1, "Maximum number of events sent at once to a telemetry sink, 1 to disable batching"),
+		TelemetryBatchInterval: flag.Float64("telemetry-batch-interval", 1, "Maximum wait for a telemetry batch to fill in seconds"),
+		RestBatchLocationPath:  flag.String("rest-batch-location-path", "/locations", "Path of the batched locations POST, relative to the resources base path"),
+		RestBatchStatusPath:    flag.String("rest-batch-status-path", "/statuses", "Path of the batched drone statuses POST, relative to the resources base path"),
+
+		AvoidanceBuffer: flag.Float64("avoidance-buffer", 50, "Distance in meters kept from the no-fly zones by avoidance routes"),
+
+		ZoneRefreshInterval: flag.Float64("zone-refresh-interval", 60, "Interval between two refreshes of the no-fly zones in seconds, 0 to load them once"),
+		ZonePageSize:        flag.Int("zone-page-size", 1000, "Number of no-fly zones fetched per request"),
+		ZonesFile:           flag.String("zones-file", "", "JSON file persisting the local no-fly zones, empty to keep them in memory only"),
+		GeofencePolicy:      flag.String("geofence-policy", "CONTINUE", "Policy of the no-fly zones without one: HOLD at the boundary, REROUTE around the zone or CONTINUE with a violation flag"),
+
+		BatteryHoverFactor: flag.Float64("battery-hover-factor", 1.2, "Consumption when hovering, relative to the consumption at cruise speed"),
+		BatteryClimbFactor: flag.Float64("battery-climb-factor", 0.5, "Extra consumption per meter per second of climb, relative to the consumption at cruise speed"),
+		DroneMass:          flag.Float64("drone-mass", 5, "Mass of a drone without payload in kilograms"),
+		Payload:            flag.Float64("payload", 0, "Mass of the payload carried by the drones in kilograms"),
+		WindSpeed:          flag.Float64("wind-speed", 0, "Wind speed in meters per second"),
+		WindDirection:      flag.Float64("wind-direction", 0, "Direction the wind blows from in degrees"),
+		BatteryChargeRate:  flag.Float64("battery-charge-rate", 4, "Charge rate at base in percent per minute, up to the taper level"),
+		BatteryChargeTaper: flag.Float64("battery-charge-taper", 80, "Battery level in percent above which the charge rate decreases linearly down to a tenth near full charge"),
+		BatteryRtlLevel:    flag.Float64("battery-rtl-level", 25, "Battery level in percent under which a drone returns to base, raised to keep the energy needed to fly back"),
+		BatteryLandLevel:   flag.Float64("battery-land-level", 5, "Battery level in percent under which a drone lands where it is"),
+
+		LinkLossTimeout:   flag.Float64("link-loss-timeout", 30, "Time in seconds after the link loss of a drone before its failsafe action"),
+		LinkFailsafe:      flag.String("link-failsafe", "RTL", "Failsafe action of a drone whose link is lost: RTL, HOLD where it is or LAND where it is"),
+		LinkCommandPolicy: flag.String("link-command-policy", "REJECT", "Commands sent to a drone whose link is lost: REJECT them or QUEUE them until the link is back"),
+		LinkBufferSize:    flag.Int("link-buffer-size", 1000, "Maximum number of telemetry events buffered per drone while its link is lost, the oldest are dropped beyond"),
+		LinkDegradedLoss:  flag.Float64("link-degraded-loss", 0.3, "Fraction of the telemetry events lost while the link of a drone is degraded"),
+
+		SensorModel:       flag.String("sensor-model", "exact", "Sensor model of the reported telemetry: exact, or noisy to add the errors of real sensors"),
+		GpsCep:            flag.Float64("gps-cep", 2.5, "Circular error probable of the GPS in meters, half of the fixes fall within it, noisy sensor model only"),
+		GpsDrift:          flag.Float64("gps-drift", 2, "Standard deviation in meters of the slowly wandering GPS bias, noisy sensor model only"),
+		GpsMultipathRate:  flag.Float64("gps-multipath-rate", 0.2, "Average number of GPS multipath bursts per minute, noisy sensor model only"),
+		GpsMultipathError: flag.Float64("gps-multipath-error", 20, "Typical GPS error in meters during a multipath burst, noisy sensor model only"),
+		BaroNoise:         flag.Float64("baro-noise", 0.5, "Standard deviation in meters of the barometric altitude, noisy sensor model only"),
+		HeadingJitter:     flag.Float64("heading-jitter", 2, "Standard deviation in degrees of the heading, noisy sensor model only"),
+		TemperatureRise:   flag.Float64("temperature-rise", 15, "Temperature rise in degrees Celsius of a drone after a long flight, lost back on the ground, noisy sensor model only"),
 	}
 
 	flag.Parse()
 	log.SetLevel(*appConfig.LogLevel)
+	if err := appConfig.validate(); err != nil {
+		panic("Invalid configuration: " + err.Error())
+	}
+}
 
+// validate checks the options the simulation steps with or divides by, which must be greater than 0
+func (c AppConfig) validate() error {
+	options := []struct {
+		name  string
+		value float64
+	}{
+		{"sim-tick", *c.SimTick},
+		{"sim-speed", *c.SimSpeed},
+		{"location-interval", *c.LocationInterval},
+		{"battery-life", *c.BatteryLife},
+		{"drone-speed", float64(*c.DroneSpeed)},
+		{"vehicle-speed", float64(*c.VehicleSpeed)},
+	}
+	for _, option := range options {
+		if option.value <= 0 {
+			return fmt.Errorf("%s must be greater than 0, got %g", option.name, option.value)
+		}
+	}
+	return nil
 }
 
 // Get Application configuration
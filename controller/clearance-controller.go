@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"h3d-drone-emulator/service"
+)
+
+func (co *Emulator) getMissionClearance(c echo.Context) error {
+	clearance, err := service.GetMissionClearance(c.Param("mission_id"))
+	if err != nil {
+		return handleErrors(c, "getMissionClearance", err)
+	}
+	return c.JSON(http.StatusOK, clearance)
+}
+
+func (co *Emulator) grantClearance(c echo.Context) error {
+	clearance, err := service.GrantClearance(c.Param("mission_id"), c.Param("zone_id"))
+	if err != nil {
+		return handleErrors(c, "grantClearance", err)
+	}
+	return c.JSON(http.StatusOK, clearance)
+}
+
+func (co *Emulator) denyClearance(c echo.Context) error {
+	clearance, err := service.DenyClearance(c.Param("mission_id"), c.Param("zone_id"))
+	if err != nil {
+		return handleErrors(c, "denyClearance", err)
+	}
+	return c.JSON(http.StatusOK, clearance)
+}
+
+func (co *Emulator) getClearanceStats(c echo.Context) error {
+	return c.JSON(http.StatusOK, service.GetClearanceStats())
+}
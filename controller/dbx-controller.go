@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"h3d-drone-emulator/service"
+)
+
+// pathParamPattern matches the {name} path parameters of the H3D paths
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// dbxIdParam is the path parameter holding the DBX id in the H3D paths
+const dbxIdParam = "dbx_id"
+
+// toEchoPath turns the {name} path parameters of a H3D path into echo :name parameters
+func toEchoPath(path string) string {
+	return pathParamPattern.ReplaceAllString(path, ":$1")
+}
+
+// toDbxEchoPath turns a H3D path into an echo path, which must hold the DBX id the handlers read
+func toDbxEchoPath(path string) string {
+	if !strings.Contains(path, "{"+dbxIdParam+"}") {
+		panic(fmt.Sprintf("DBX path %s has no {%s} parameter", path, dbxIdParam))
+	}
+	return toEchoPath(path)
+}
+
+func (co *Emulator) getAllDroneServers(c echo.Context) error {
+	servers, err := service.GetAllDroneServers()
+	if err != nil {
+		return handleErrors(c, "getAllDroneServers", err)
+	}
+	return c.JSON(http.StatusOK, servers)
+}
+
+func (co *Emulator) readDroneServer(c echo.Context) error {
+	read, err := service.GetDroneServer(c.Param(dbxIdParam))
+	if err != nil {
+		return handleErrors(c, "readDroneServer", err)
+	}
+	return c.JSON(http.StatusOK, read)
+}
+
+func (co *Emulator) getDroneServerVideo(c echo.Context) error {
+	video, err := service.GetDroneServerVideo(c.Param(dbxIdParam))
+	if err != nil {
+		return handleErrors(c, "getDroneServerVideo", err)
+	}
+	return c.JSON(http.StatusOK, video)
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
 	"h3d-drone-emulator/config"
 	"h3d-drone-emulator/models"
@@ -10,9 +11,6 @@ import (
 
 	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
 
-	restrictedZone "h3d-drone-emulator/util"
-	"time"
-
 	"github.com/labstack/echo/v4"
 )
 
@@ -34,6 +32,9 @@ var appConfig config.AppConfig
 func (co *Emulator) Initialize(e *echo.Echo) {
 	appConfig = config.Get()
 	pathParamDroneId := "/:drone_id"
+	pathParamResourceId := "/:resource_id"
+	pathParamZoneId := "/:zone_id"
+	pathParamMissionId := "/:mission_id"
 	// REST Classic APIs
 	groupRest := e.Group(*appConfig.EndPointUrl + *appConfig.VersionPath)
 	groupRest.GET(*appConfig.GetHealthPath, co.isHealthy)
@@ -41,14 +42,41 @@ func (co *Emulator) Initialize(e *echo.Echo) {
 	groupRest.GET(*appConfig.DroneBasePath+pathParamDroneId+*appConfig.DroneVideoPath, co.getDroneVideo)
 	groupRest.GET(*appConfig.AllDronesPath, co.getAllDrones)
 	groupRest.GET(*appConfig.AllDroneServersPath, co.getAllDroneServers)
+	groupRest.GET(toDbxEchoPath(*appConfig.DroneServerPath), co.readDroneServer)
+	groupRest.GET(toDbxEchoPath(*appConfig.VideoFeedPath), co.getDroneServerVideo)
 	// groupRest.GET(*appConfig.DroneBasePath+pathParamDroneId+*appConfig.StopMissionPath, co.stopMission)
 	// groupRest.POST(*appConfig.StartMissionPath, co.startMission)
 	groupRest.POST(*appConfig.AllFlightsPath, co.getAllFlights)
 	groupRest.POST(*appConfig.GetMissionPath, co.getMissionDetails)
 	groupRest.GET("/resources", co.getAllResources)
+	groupRest.GET("/resources"+pathParamResourceId+"/state", co.getResourceState)
+	groupRest.POST("/resources"+pathParamResourceId+"/recover", co.recoverResource)
+	groupRest.GET("/resources"+pathParamResourceId+"/faults", co.getResourceFaults)
+	groupRest.POST("/resources"+pathParamResourceId+"/faults", co.applyResourceFault)
+	groupRest.GET("/resources"+pathParamResourceId+"/link", co.getResourceLink)
+	groupRest.POST("/resources"+pathParamResourceId+"/link", co.setResourceLink)
 	groupRest.POST("/mission/start", co.startResourceMission)
 	groupRest.POST("/mission/stop", co.StopResourceMission)
 	groupRest.GET(*appConfig.GetRoutePath, co.getRouteDetails)
+	groupRest.GET("/sim/clock", co.getSimClock)
+	groupRest.POST("/sim/clock/pause", co.pauseSimClock)
+	groupRest.POST("/sim/clock/resume", co.resumeSimClock)
+	groupRest.POST("/sim/clock/speed", co.setSimClockSpeed)
+	groupRest.POST("/sim/clock/step", co.stepSimClock)
+	groupRest.GET("/telemetry/stats", co.getTelemetryStats)
+	groupRest.GET("/stream", co.streamEvents)
+	groupRest.GET("/stream/ws", co.streamEventsWebSocket)
+	groupRest.GET("/zones/registry", co.getZoneRegistry)
+	groupRest.GET("/zones", co.listZones)
+	groupRest.POST("/zones", co.createZone)
+	groupRest.GET("/zones"+pathParamZoneId, co.getZone)
+	groupRest.PUT("/zones"+pathParamZoneId, co.updateZone)
+	groupRest.DELETE("/zones"+pathParamZoneId, co.deleteZone)
+	groupRest.GET("/mission"+pathParamMissionId+"/clearance", co.getMissionClearance)
+	groupRest.POST("/mission"+pathParamMissionId+"/clearance"+pathParamZoneId+"/grant", co.grantClearance)
+	groupRest.POST("/mission"+pathParamMissionId+"/clearance"+pathParamZoneId+"/deny", co.denyClearance)
+	groupRest.GET("/clearance/stats", co.getClearanceStats)
+	groupRest.GET("/scenario", co.getScenario)
 }
 
 // isHealthy godoc
@@ -123,6 +151,32 @@ func (co *Emulator) getAllResources(c echo.Context) error {
 	return c.JSON(http.StatusOK, resources)
 }
 
+func (co *Emulator) getResourceState(c echo.Context) error {
+	resourceId, bindErr := bindResourceIdParam(c)
+	if bindErr != nil {
+		return handleBadRequest(c, bindErr)
+	}
+
+	state, err := service.GetResourceState(resourceId)
+	if err != nil {
+		return handleErrors(c, "getResourceState", err)
+	}
+	return c.JSON(http.StatusOK, state)
+}
+
+func (co *Emulator) recoverResource(c echo.Context) error {
+	resourceId, bindErr := bindResourceIdParam(c)
+	if bindErr != nil {
+		return handleBadRequest(c, bindErr)
+	}
+
+	state, err := service.RecoverResource(resourceId)
+	if err != nil {
+		return handleErrors(c, "recoverResource", err)
+	}
+	return c.JSON(http.StatusOK, state)
+}
+
 func (co *Emulator) getAllFlights(c echo.Context) error {
 	//log.Info("getAllFlights")
 	rc := models.CreateRequestContext(c)
@@ -133,16 +187,6 @@ func (co *Emulator) getAllFlights(c echo.Context) error {
 	return c.JSON(http.StatusOK, "Getting All Flights")
 }
 
-func (co *Emulator) getAllDroneServers(c echo.Context) error {
-	//log.Info("getAllDroneServers")
-	rc := models.CreateRequestContext(c)
-	err := service.GetAllDroneServers(rc)
-	if err != nil {
-		return handleErrors(c, "getAllDroneServers", err)
-	}
-	return c.JSON(http.StatusOK, "Getting All Drone Servers")
-}
-
 // func (co *Emulator) startMission(c echo.Context) error {
 // 	//log.Info("startMission")
 // 	rc := models.CreateRequestContext(c)
@@ -172,6 +216,9 @@ func (co *Emulator) startResourceMission(c echo.Context) error {
 	log.Debug("Resource Mission - %#v", mission)
 	err := service.StartResourceMission(*mission)
 
+	if errors.Is(err, service.ErrCommandQueued) {
+		return c.JSON(http.StatusAccepted, mission)
+	}
 	if err != nil {
 		return handleErrors(c, "startResourceMission", err)
 	}
@@ -188,6 +235,9 @@ func (co *Emulator) StopResourceMission(c echo.Context) error {
 	log.Debug("Resource Mission - %#v", mission)
 	err := service.StopResourceMission(*mission)
 
+	if errors.Is(err, service.ErrCommandQueued) {
+		return c.JSON(http.StatusAccepted, mission)
+	}
 	if err != nil {
 		return handleErrors(c, "stopResourceMission", err)
 	}
@@ -281,6 +331,14 @@ func bindMissionCommandParam(c echo.Context) (*models.MissionCommand, error) {
 	return mc, nil
 }
 
+func bindResourceIdParam(c echo.Context) (string, error) {
+	resourceId := c.Param("resource_id")
+	if resourceId != "" {
+		return resourceId, nil
+	}
+	return "", fmt.Errorf("error in bindResourceIdParam")
+}
+
 func bindDroneIdParam(c echo.Context) (string, error) {
 	droneId := c.Param("drone_id")
 	if droneId != "" {
@@ -292,196 +350,24 @@ func bindDroneIdParam(c echo.Context) (string, error) {
 // Route Path start
 
 func (co *Emulator) getRouteDetails(c echo.Context) error {
-	var clearanceRequired bool
 	rc := models.CreateRequestContext(c)
 
-	query, bindErr := bindParamQuery(c)
-	if bindErr != nil {
-		return handleBadRequest(c, bindErr)
-	}
-
-	routeType, bindErr := bindParamRouteType(c)
-	if bindErr != nil {
-		return handleBadRequest(c, bindErr)
-	}
-
-	resorurceId, bindErr := bindParamResourceId(c)
-	if bindErr != nil {
-		return handleBadRequest(c, bindErr)
-	}
-
-	travelMode, bindErr := bindParamTravelMode(c)
-	if bindErr != nil {
-		return handleBadRequest(c, bindErr)
-	}
-
-	computeBestOrder, bindErr := bindParamComputeBestOrder(c)
-	if bindErr != nil {
-		return handleBadRequest(c, bindErr)
-	}
-
-	routeRepresentation, bindErr := bindParamRouteRepresentation(c)
-	if bindErr != nil {
-		return handleBadRequest(c, bindErr)
-	}
-
-	distance, clearenceZonesCrossed, err := service.GetPath(rc, query)
-
+	response, err := service.PlanRoute(rc, bindRouteRequestParam(c))
 	if err != nil {
 		return handleErrors(c, "getRouteDetails", err)
-
-	} else {
-
-		travelTimeInSeconds := calculateTime(distance)
-
-		dispatchTime := *appConfig.DispatchTime
-		clearanceTime := *appConfig.ClearanceTime
-
-		clearanceRequired = false
-
-		if len(clearenceZonesCrossed) > 0 {
-			// Add clearanceTime and dispatchTime to travelTimeInSeconds
-			travelTimeInSeconds = travelTimeInSeconds + float64(clearanceTime+dispatchTime)
-			clearanceRequired = true
-		} else {
-			travelTimeInSeconds = travelTimeInSeconds + float64(dispatchTime)
-			clearanceRequired = false
-		}
-
-		startTime := time.Now()
-
-		// Format the current time in the desired format
-		formattedStartTime := startTime.Format("2006-01-02T15:04:05-07:00")
-		fmt.Println("Current Time:", formattedStartTime)
-
-		// Define the number of seconds to add
-		secondsToAdd := travelTimeInSeconds // Example: add 120 seconds (2 minutes)
-
-		// Calculate the end time by adding seconds
-		endTime := startTime.Add(time.Duration(secondsToAdd) * time.Second)
-
-		// Format the end time in the desired format
-		formattedEndTime := endTime.Format("2006-01-02T15:04:05-07:00")
-		fmt.Println("End Time after adding seconds:", formattedEndTime)
-
-		numWaypoints := 10
-
-		source, destination, error := service.GetSourceDestinationPoints(query)
-
-		waypoints := service.GetWaypoints(source, destination, startTime, endTime, numWaypoints)
-
-		if error != nil {
-			return handleErrors(c, "GetSourceDestinationPoints", err)
-		}
-
-		remainingOperationTimeAtLocation := service.GetRemainingOperationTimeAtLocation(resorurceId, travelTimeInSeconds)
-
-		return getRouteRestrictedZone(c, startTime, endTime, clearanceRequired, travelTimeInSeconds, distance, source, destination, waypoints, remainingOperationTimeAtLocation, clearenceZonesCrossed)
-
 	}
-
-	return c.JSON(http.StatusOK, "getRouteDetails"+"-"+query+routeRepresentation+routeType+resorurceId+travelMode+computeBestOrder)
-}
-
-func getRouteRestrictedZone(c echo.Context, startTime time.Time, endTime time.Time, clearanceRequired bool, travelTimeInSeconds float64, distance float64, source restrictedZone.Point, destination restrictedZone.Point, waypoints []models.Point, remainingOperationTimeAtLocation float64, clearenceZonesCrossed []models.ClearanceZone) error {
-	// Create a mock response
-	year, month, day := startTime.Date()
-	hour, minute, second := startTime.Clock()
-
-	// Convert to time.Date using the extracted components
-	endYear, endMonth, endDay := endTime.Date()
-	endHour, endMinute, endSecond := endTime.Clock()
-
-	response := models.RouteResponse{
-		Routes: []models.Route{
-			{
-				Summary: models.RouteSummary{
-					LengthInMeters:                   int(distance),
-					TravelTimeInSeconds:              int(travelTimeInSeconds),
-					TrafficDelayInSeconds:            0,
-					TrafficLengthInMeters:            0,
-					DepartureTime:                    time.Date(year, month, day, hour, minute, second, 0, time.Local),
-					ArrivalTime:                      time.Date(endYear, endMonth, endDay, endHour, endMinute, endSecond, 0, time.Local),
-					ClearanceRequired:                clearanceRequired,
-					RemainingOperationTimeAtLocation: int(remainingOperationTimeAtLocation),
-					ClearanceZones:                   clearenceZonesCrossed,
-				},
-				Legs: []models.Leg{
-					{
-						Summary: models.LegSummary{
-							LengthInMeters:                   int(distance),
-							TravelTimeInSeconds:              int(travelTimeInSeconds),
-							TrafficDelayInSeconds:            0,
-							TrafficLengthInMeters:            0,
-							DepartureTime:                    time.Date(year, month, day, hour, minute, second, 0, time.Local),
-							ArrivalTime:                      time.Date(endYear, endMonth, endDay, endHour, endMinute, endSecond, 0, time.Local),
-							ClearanceRequired:                clearanceRequired,
-							RemainingOperationTimeAtLocation: int(remainingOperationTimeAtLocation),
-							ClearanceZones:                   clearenceZonesCrossed,
-						},
-						Points: waypoints,
-					},
-				},
-			},
-		},
-	}
-
-	// Return the JSON response
 	return c.JSON(http.StatusOK, response)
 }
 
-func calculateTime(distance float64) float64 {
-	speed := 1.0 // speed in 1meter/second
-	time := distance / speed
-	return time // time in seconds
-}
-
-func bindParamQuery(c echo.Context) (string, error) {
-	query := c.QueryParam("query")
-	if query != "" {
-		return c.QueryParam("query"), nil
-	}
-	return "", fmt.Errorf("error in bindDroneIdParam")
-}
-
-func bindParamRouteType(c echo.Context) (string, error) {
-	routeType := c.QueryParam("routeType")
-	if routeType != "" {
-		return c.QueryParam("routeType"), nil
-	}
-	return "", fmt.Errorf("error in bindDroneIdParam")
-}
-
-func bindParamTravelMode(c echo.Context) (string, error) {
-	travelMode := c.QueryParam("travelMode")
-	if travelMode != "" {
-		return c.QueryParam("travelMode"), nil
-	}
-	return "", fmt.Errorf("error in bindDroneIdParam")
-}
-
-func bindParamRouteRepresentation(c echo.Context) (string, error) {
-	routeRepresentation := c.QueryParam("routeRepresentation")
-	if routeRepresentation != "" {
-		return c.QueryParam("routeRepresentation"), nil
-	}
-	return "", fmt.Errorf("error in bindDroneIdParam")
-}
-
-func bindParamComputeBestOrder(c echo.Context) (string, error) {
-	computeBestOrder := c.QueryParam("computeBestOrder")
-	if computeBestOrder != "" {
-		return c.QueryParam("computeBestOrder"), nil
+func bindRouteRequestParam(c echo.Context) models.RouteRequest {
+	return models.RouteRequest{
+		Query:               c.QueryParam("query"),
+		RouteType:           c.QueryParam("routeType"),
+		TravelMode:          c.QueryParam("travelMode"),
+		ComputeBestOrder:    c.QueryParam("computeBestOrder"),
+		RouteRepresentation: c.QueryParam("routeRepresentation"),
+		ResourceId:          c.QueryParam("resourceId"),
 	}
-	return "", fmt.Errorf("error in computeBestOrder")
-}
-
-func bindParamResourceId(c echo.Context) (string, error) {
-	resourceId := c.QueryParam("resourceId")
-	if resourceId != "" {
-		return c.QueryParam("query"), nil
-	}
-	return "", fmt.Errorf("error in bindDroneIdParam")
 }
 
 /*
@@ -489,6 +375,21 @@ func bindParamResourceId(c echo.Context) (string, error) {
 TODO Find a way to optimize this function
 */
 func handleErrors(c echo.Context, ID string, err error) error {
+	var transitionErr *service.TransitionError
+	if errors.Is(err, service.ErrResourceNotFound) || errors.Is(err, service.ErrZoneNotFound) || errors.Is(err, service.ErrClearanceNotFound) ||
+		errors.Is(err, service.ErrDbxNotFound) {
+		return handleNotFound(c, err)
+	}
+	if errors.As(err, &transitionErr) || errors.Is(err, service.ErrZoneExists) || errors.Is(err, service.ErrClearanceDecided) ||
+		errors.Is(err, service.ErrClearanceDenied) || errors.Is(err, service.ErrLinkLost) ||
+		errors.Is(err, service.ErrNotRecoverable) {
+		return handleConflict(c, err)
+	}
+	if errors.Is(err, service.ErrInvalidMission) || errors.Is(err, service.ErrInvalidClockCommand) || errors.Is(err, service.ErrInvalidStreamFilter) ||
+		errors.Is(err, service.ErrInvalidRoute) || errors.Is(err, service.ErrInvalidZone) || errors.Is(err, service.ErrInvalidFault) ||
+		errors.Is(err, service.ErrInvalidLink) {
+		return handleBadRequest(c, err)
+	}
 	if strings.Contains(err.Error(), "Unknown id") || strings.Contains(err.Error(), "Value too long for type") {
 		return handleBadRequest(c, err)
 	}
@@ -506,6 +407,14 @@ func handleBadRequest(c echo.Context, err error) error {
 	return c.JSON(http.StatusBadRequest, err.Error())
 }
 
+func handleNotFound(c echo.Context, err error) error {
+	return c.JSON(http.StatusNotFound, err.Error())
+}
+
+func handleConflict(c echo.Context, err error) error {
+	return c.JSON(http.StatusConflict, err.Error())
+}
+
 func (co *Emulator) Dispose() error {
 	// Nothing to do
 	service.Dispose()
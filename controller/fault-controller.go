@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
+
+	"h3d-drone-emulator/models"
+	"h3d-drone-emulator/service"
+)
+
+func (co *Emulator) getResourceFaults(c echo.Context) error {
+	resourceFaults, err := service.GetResourceFaults(c.Param("resource_id"))
+	if err != nil {
+		return handleErrors(c, "getResourceFaults", err)
+	}
+	return c.JSON(http.StatusOK, resourceFaults)
+}
+
+func (co *Emulator) applyResourceFault(c echo.Context) error {
+	command, bindErr := bindFaultCommandParam(c)
+	if bindErr != nil {
+		return handleBadRequest(c, bindErr)
+	}
+	resourceFaults, err := service.ApplyFaultCommand(c.Param("resource_id"), *command)
+	if err != nil {
+		return handleErrors(c, "applyResourceFault", err)
+	}
+	return c.JSON(http.StatusOK, resourceFaults)
+}
+
+func bindFaultCommandParam(c echo.Context) (*models.FaultCommand, error) {
+	command := new(models.FaultCommand)
+	if err := c.Bind(command); err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+	return command, nil
+}
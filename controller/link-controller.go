@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
+
+	"h3d-drone-emulator/models"
+	"h3d-drone-emulator/service"
+)
+
+func (co *Emulator) getResourceLink(c echo.Context) error {
+	linkStatus, err := service.GetLinkStatus(c.Param("resource_id"))
+	if err != nil {
+		return handleErrors(c, "getResourceLink", err)
+	}
+	return c.JSON(http.StatusOK, linkStatus)
+}
+
+func (co *Emulator) setResourceLink(c echo.Context) error {
+	command, bindErr := bindLinkCommandParam(c)
+	if bindErr != nil {
+		return handleBadRequest(c, bindErr)
+	}
+	linkStatus, err := service.SetLinkState(c.Param("resource_id"), *command)
+	if err != nil {
+		return handleErrors(c, "setResourceLink", err)
+	}
+	return c.JSON(http.StatusOK, linkStatus)
+}
+
+func bindLinkCommandParam(c echo.Context) (*models.LinkCommand, error) {
+	command := new(models.LinkCommand)
+	if err := c.Bind(command); err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+	return command, nil
+}
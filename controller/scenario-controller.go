@@ -0,0 +1,13 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"h3d-drone-emulator/service"
+)
+
+func (co *Emulator) getScenario(c echo.Context) error {
+	return c.JSON(http.StatusOK, service.GetScenarioStatus())
+}
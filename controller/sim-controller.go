@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
+
+	"h3d-drone-emulator/models"
+	"h3d-drone-emulator/service"
+)
+
+func (co *Emulator) getSimClock(c echo.Context) error {
+	return c.JSON(http.StatusOK, service.GetClockStatus())
+}
+
+func (co *Emulator) pauseSimClock(c echo.Context) error {
+	log.Info("Pausing simulation clock")
+	return c.JSON(http.StatusOK, service.PauseClock())
+}
+
+func (co *Emulator) resumeSimClock(c echo.Context) error {
+	log.Info("Resuming simulation clock")
+	return c.JSON(http.StatusOK, service.ResumeClock())
+}
+
+func (co *Emulator) setSimClockSpeed(c echo.Context) error {
+	command, bindErr := bindSimClockCommandParam(c)
+	if bindErr != nil {
+		return handleBadRequest(c, bindErr)
+	}
+
+	status, err := service.SetClockSpeed(*command)
+	if err != nil {
+		return handleErrors(c, "setSimClockSpeed", err)
+	}
+	return c.JSON(http.StatusOK, status)
+}
+
+func (co *Emulator) stepSimClock(c echo.Context) error {
+	command, bindErr := bindSimClockCommandParam(c)
+	if bindErr != nil {
+		return handleBadRequest(c, bindErr)
+	}
+
+	status, err := service.StepClock(*command)
+	if err != nil {
+		return handleErrors(c, "stepSimClock", err)
+	}
+	return c.JSON(http.StatusOK, status)
+}
+
+func bindSimClockCommandParam(c echo.Context) (*models.SimClockCommand, error) {
+	command := new(models.SimClockCommand)
+	if err := c.Bind(command); err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+	return command, nil
+}
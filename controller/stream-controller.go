@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
+	"golang.org/x/net/websocket"
+
+	"h3d-drone-emulator/models"
+	"h3d-drone-emulator/service"
+)
+
+// streamHeartbeat is the interval of the keep-alive comments sent to idle SSE clients
+const streamHeartbeat = 15 * time.Second
+
+// streamEvents pushes the live events as Server-Sent Events
+func (co *Emulator) streamEvents(c echo.Context) error {
+	events, cancel, err := service.SubscribeStream(bindStreamFilterParam(c))
+	if err != nil {
+		return handleErrors(c, "streamEvents", err)
+	}
+	defer cancel()
+	log.Info("SSE client connected from %s", c.Request().RemoteAddr)
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			log.Info("SSE client %s disconnected", c.Request().RemoteAddr)
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Error(err.Error())
+				continue
+			}
+			fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event.Type, data)
+			res.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(res, ": keep-alive\n\n")
+			res.Flush()
+		}
+	}
+}
+
+// streamEventsWebSocket pushes the live events as JSON messages over a WebSocket
+func (co *Emulator) streamEventsWebSocket(c echo.Context) error {
+	events, cancel, err := service.SubscribeStream(bindStreamFilterParam(c))
+	if err != nil {
+		return handleErrors(c, "streamEventsWebSocket", err)
+	}
+	defer cancel()
+
+	server := websocket.Server{
+		// Accept clients without Origin header, such as command line tools
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			log.Info("WebSocket client connected from %s", c.Request().RemoteAddr)
+			closed := make(chan struct{})
+			go func() {
+				// Incoming messages are ignored, reading only detects the client leaving
+				var message string
+				for websocket.Message.Receive(ws, &message) == nil {
+				}
+				close(closed)
+			}()
+			for {
+				select {
+				case <-closed:
+					log.Info("WebSocket client %s disconnected", c.Request().RemoteAddr)
+					return
+				case event, ok := <-events:
+					if !ok {
+						return
+					}
+					if err := websocket.JSON.Send(ws, event); err != nil {
+						log.Error(err.Error())
+						return
+					}
+				}
+			}
+		},
+	}
+	server.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
+// bindStreamFilterParam reads the resourceId, resourceType and type query parameters,
+// each one accepts comma separated values and may be repeated
+func bindStreamFilterParam(c echo.Context) models.StreamFilter {
+	return models.StreamFilter{
+		ResourceIds:   getListQueryParam(c, "resourceId"),
+		ResourceTypes: getListQueryParam(c, "resourceType"),
+		EventTypes:    getListQueryParam(c, "type"),
+	}
+}
+
+func getListQueryParam(c echo.Context, name string) []string {
+	values := make([]string, 0)
+	for _, param := range c.QueryParams()[name] {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
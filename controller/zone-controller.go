@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
+
+	"h3d-drone-emulator/models"
+	"h3d-drone-emulator/service"
+)
+
+func (co *Emulator) getZoneRegistry(c echo.Context) error {
+	return c.JSON(http.StatusOK, service.GetZoneRegistryStatus())
+}
+
+func (co *Emulator) listZones(c echo.Context) error {
+	return c.JSON(http.StatusOK, service.ListZones())
+}
+
+func (co *Emulator) getZone(c echo.Context) error {
+	zone, err := service.GetZone(c.Param("zone_id"))
+	if err != nil {
+		return handleErrors(c, "getZone", err)
+	}
+	return c.JSON(http.StatusOK, zone)
+}
+
+func (co *Emulator) createZone(c echo.Context) error {
+	zone, bindErr := bindZoneParam(c)
+	if bindErr != nil {
+		return handleBadRequest(c, bindErr)
+	}
+	created, err := service.CreateZone(*zone)
+	if err != nil {
+		return handleErrors(c, "createZone", err)
+	}
+	return c.JSON(http.StatusCreated, created)
+}
+
+func (co *Emulator) updateZone(c echo.Context) error {
+	zone, bindErr := bindZoneParam(c)
+	if bindErr != nil {
+		return handleBadRequest(c, bindErr)
+	}
+	updated, err := service.UpdateZone(c.Param("zone_id"), *zone)
+	if err != nil {
+		return handleErrors(c, "updateZone", err)
+	}
+	return c.JSON(http.StatusOK, updated)
+}
+
+func (co *Emulator) deleteZone(c echo.Context) error {
+	if err := service.DeleteZone(c.Param("zone_id")); err != nil {
+		return handleErrors(c, "deleteZone", err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func bindZoneParam(c echo.Context) (*models.Zone, error) {
+	zone := new(models.Zone)
+	if err := c.Bind(zone); err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+	return zone, nil
+}
@@ -26,10 +26,6 @@ var (
 )
 
 func main() {
-	// Getting configuration from config.*.json
-	config.Load()
-	log.Info("Loaded Configuration")
-
 	defer func() { // recover the panic and exit -1
 		if err := recover(); err != nil { //
 			log.Error("panic: %v\n", err)
@@ -38,6 +34,10 @@ func main() {
 		}
 	}()
 
+	// Getting configuration from config.*.json
+	config.Load()
+	log.Info("Loaded Configuration")
+
 	if GitCommit != "" {
 		log.Info("GitCommit : [%s]", GitCommit)
 	}
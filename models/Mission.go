@@ -10,14 +10,53 @@ type Mission struct {
 }
 
 type MissionCommand struct {
-	OperationId    *string     `json:"operationId"`
-	ResourceId     *string     `json:"resourceId"`
-	MissionId      *string     `json:"missionId"`
-	MissionName    *string     `json:"missionName"`
-	MissionType    *string     `json:"missionType"`
-	Waypoints      [][]float64 `json:"waypoints"`
-	GenTimestampMs int64       `json:"genTimestampMs"`
+	OperationId      *string           `json:"operationId"`
+	ResourceId       *string           `json:"resourceId"`
+	MissionId        *string           `json:"missionId"`
+	MissionName      *string           `json:"missionName"`
+	MissionType      *string           `json:"missionType"`
+	Waypoints        [][]float64       `json:"waypoints"`
+	MissionWaypoints []MissionWaypoint `json:"missionWaypoints"`
+	OnComplete       string            `json:"onComplete"`
+	GenTimestampMs   int64             `json:"genTimestampMs"`
 }
+
+// Mission completion behaviours
+const (
+	MissionCompleteRTL  = "RTL"
+	MissionCompleteHold = "HOLD"
+	MissionCompleteLoop = "LOOP"
+)
+
+// MissionWaypoint is a waypoint of a mission with its optional flight parameters
+type MissionWaypoint struct {
+	Latitude      float64  `json:"latitude"`
+	Longitude     float64  `json:"longitude"`
+	Altitude      *float64 `json:"altitude"`
+	Speed         *float64 `json:"speed"`
+	LoiterSeconds int      `json:"loiterSeconds"`
+}
+
+// GetMissionWaypoints returns the waypoints of the mission, missionWaypoints takes precedence over waypoints
+func (mc MissionCommand) GetMissionWaypoints() []MissionWaypoint {
+	if len(mc.MissionWaypoints) > 0 {
+		return mc.MissionWaypoints
+	}
+	waypoints := make([]MissionWaypoint, 0, len(mc.Waypoints))
+	for _, waypoint := range mc.Waypoints {
+		if len(waypoint) < 2 {
+			continue
+		}
+		missionWaypoint := MissionWaypoint{Latitude: waypoint[0], Longitude: waypoint[1]}
+		if len(waypoint) > 2 {
+			altitude := waypoint[2]
+			missionWaypoint.Altitude = &altitude
+		}
+		waypoints = append(waypoints, missionWaypoint)
+	}
+	return waypoints
+}
+
 type NewMission struct {
 	MissionName string `json:"missionName"`
 }
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Status of a clearance request
+const (
+	ClearancePending = "PENDING"
+	ClearanceGranted = "GRANTED"
+	ClearanceDenied  = "DENIED"
+)
+
+// MissionClearance is the clearance of a mission for the no-fly zones crossed by its path.
+// It is granted once every zone is granted and denied as soon as one zone is denied.
+type MissionClearance struct {
+	MissionId  string          `json:"missionId"`
+	ResourceId string          `json:"resourceId,omitempty"`
+	Status     string          `json:"status"`
+	Zones      []ZoneClearance `json:"zones"`
+}
+
+// ZoneClearance is the clearance of a mission for one zone.
+// Clearances decided before the mission starts have no request time nor time to clearance.
+type ZoneClearance struct {
+	ZoneId                 string     `json:"zoneId"`
+	Status                 string     `json:"status"`
+	EntryTime              *time.Time `json:"entryTime,omitempty"`
+	ExitTime               *time.Time `json:"exitTime,omitempty"`
+	RequestedAt            *time.Time `json:"requestedAt,omitempty"`
+	DecidedAt              *time.Time `json:"decidedAt,omitempty"`
+	TimeToClearanceSeconds *float64   `json:"timeToClearanceSeconds,omitempty"`
+}
+
+// ClearanceStats are the observed times to clearance, overall and per zone
+type ClearanceStats struct {
+	Granted                       int                  `json:"granted"`
+	Denied                        int                  `json:"denied"`
+	AverageTimeToClearanceSeconds *float64             `json:"averageTimeToClearanceSeconds,omitempty"`
+	Zones                         []ZoneClearanceStats `json:"zones"`
+}
+
+// ZoneClearanceStats are the observed times to clearance of a zone
+type ZoneClearanceStats struct {
+	ZoneId                        string   `json:"zoneId"`
+	Granted                       int      `json:"granted"`
+	Denied                        int      `json:"denied"`
+	AverageTimeToClearanceSeconds *float64 `json:"averageTimeToClearanceSeconds,omitempty"`
+}
@@ -0,0 +1,56 @@
+package models
+
+// DroneBox is a DBX, the drone box serving a set of drones to the H3D cloud
+type DroneBox struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	SerialNo  string  `json:"serialNo"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// DroneIds are the drones of the DBX, the drones of no DBX are spread over the DBXs
+	DroneIds []string `json:"droneIds"`
+}
+
+// Status of a DBX, offline when the links of all its drones are lost
+const (
+	DbxOnline  = "online"
+	DbxOffline = "offline"
+)
+
+// DbxH3DResponse is a DBX in the H3D format
+type DbxH3DResponse struct {
+	DbxId       Dbx              `json:"_id"`
+	Name        string           `json:"name"`
+	SerialNo    string           `json:"serial_no"`
+	CreateBy    string           `json:"create_by"`
+	Company     string           `json:"company"`
+	Status      string           `json:"status"`
+	Position    string           `json:"position"`
+	Drones      []DroneH3DId     `json:"drones"`
+	TimestampMs DroneH3dCreateAt `json:"create_at"`
+}
+
+// DbxH3dDroneStatus is the status of a drone read from its DBX, in the H3D format
+type DbxH3dDroneStatus struct {
+	DroneId DroneH3DId `json:"_id"`
+	DroneH3dStatus
+}
+
+// DbxH3dRead is the status of the drones of a DBX, in the H3D format
+type DbxH3dRead struct {
+	DbxId  Dbx                 `json:"_id"`
+	Status string              `json:"status"`
+	Drones []DbxH3dDroneStatus `json:"drones"`
+}
+
+// DbxH3dDroneVideo is the video feed of a drone of a DBX, in the H3D format
+type DbxH3dDroneVideo struct {
+	DroneId DroneH3DId `json:"_id"`
+	DroneVideo
+}
+
+// DbxH3dVideo is the video feeds of the drones of a DBX, in the H3D format
+type DbxH3dVideo struct {
+	DbxId  Dbx                `json:"_id"`
+	Drones []DbxH3dDroneVideo `json:"drones"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Faults which can be injected in a resource
+const (
+	FaultGpsLoss       = "GPS_LOSS"
+	FaultGpsDegraded   = "GPS_DEGRADED"
+	FaultLinkLoss      = "LINK_LOSS"
+	FaultMotorFailure  = "MOTOR_FAILURE"
+	FaultOverheating   = "OVERHEATING"
+	FaultCompassError  = "COMPASS_ERROR"
+	FaultLowBattery    = "LOW_BATTERY"
+	FaultStuckPosition = "STUCK_POSITION"
+)
+
+// Keys of the active faults in the textual status of a drone
+const (
+	TextualStatusFaults    = "faults"
+	TextualStatusErrorCode = "error_code"
+)
+
+// FaultCommand injects or clears a fault, immediately or after a delay of simulation time.
+// An injected fault with a duration is cleared once the duration is elapsed.
+type FaultCommand struct {
+	Type            string  `json:"type"`
+	Clear           bool    `json:"clear"`
+	DelaySeconds    float64 `json:"delaySeconds,omitempty"`
+	DurationSeconds float64 `json:"durationSeconds,omitempty"`
+}
+
+// Fault is a fault active on a resource
+type Fault struct {
+	Type      string     `json:"type"`
+	ErrorCode int        `json:"errorCode"`
+	Since     time.Time  `json:"since"`
+	Until     *time.Time `json:"until,omitempty"`
+}
+
+// ScheduledFault is a fault command waiting for its time
+type ScheduledFault struct {
+	Type  string    `json:"type"`
+	Clear bool      `json:"clear"`
+	At    time.Time `json:"at"`
+}
+
+// ResourceFaults are the active and scheduled faults of a resource,
+// the error code is the highest error code of the active faults, 0 without fault
+type ResourceFaults struct {
+	ResourceId string           `json:"resourceId"`
+	ErrorCode  int              `json:"errorCode"`
+	Faults     []Fault          `json:"faults"`
+	Scheduled  []ScheduledFault `json:"scheduled"`
+}
@@ -0,0 +1,31 @@
+package models
+
+// Policies applied to a resource about to enter an active no-fly zone
+const (
+	GeofencePolicyHold     = "HOLD"
+	GeofencePolicyReroute  = "REROUTE"
+	GeofencePolicyContinue = "CONTINUE"
+)
+
+// Geofence actions reported on the live stream
+const (
+	GeofenceEnter   = "ENTER"
+	GeofenceExit    = "EXIT"
+	GeofenceHold    = "HOLD"
+	GeofenceReroute = "REROUTE"
+)
+
+// Keys of the geofence violation flag in the textual status of a drone
+const (
+	TextualStatusGeofenceViolation = "geofence_violation"
+	TextualStatusGeofenceZones     = "geofence_zones"
+)
+
+// GeofenceEvent is a resource entering or leaving a no-fly zone, or being stopped or rerouted at its boundary
+type GeofenceEvent struct {
+	ZoneId    string  `json:"zoneId"`
+	Action    string  `json:"action"`
+	Policy    string  `json:"policy"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
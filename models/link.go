@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// States of the command and telemetry link of a resource
+const (
+	LinkConnected = "CONNECTED"
+	LinkDegraded  = "DEGRADED"
+	LinkLost      = "LOST"
+)
+
+// Failsafe actions run once the link of a resource is lost for too long
+const (
+	LinkFailsafeRTL  = "RTL"
+	LinkFailsafeHold = "HOLD"
+	LinkFailsafeLand = "LAND"
+)
+
+// Policies of the commands sent to a resource whose link is lost
+const (
+	LinkCommandsReject = "REJECT"
+	LinkCommandsQueue  = "QUEUE"
+)
+
+// LinkCommand changes the link state of a resource
+type LinkCommand struct {
+	State string `json:"state"`
+}
+
+// LinkStatus is the link of a resource with the telemetry buffered and the commands queued while it is lost.
+// Dropped counts the telemetry events lost, either on a degraded link or beyond the buffer size.
+type LinkStatus struct {
+	ResourceId     string     `json:"resourceId"`
+	State          string     `json:"state"`
+	Since          time.Time  `json:"since"`
+	Buffered       int        `json:"buffered"`
+	Dropped        int        `json:"dropped"`
+	QueuedCommands int        `json:"queuedCommands"`
+	FailsafeAt     *time.Time `json:"failsafeAt,omitempty"`
+	FailsafeDone   bool       `json:"failsafeDone"`
+}
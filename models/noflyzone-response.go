@@ -23,6 +23,10 @@ type Geometry struct {
 type Data struct {
 	ActivationEnd   ActivationTime `json:"activationEnd"`
 	ActivationStart ActivationTime `json:"activationStart"`
+	// Radius in meters of the zones defined by a Point
+	Radius float64 `json:"radius"`
+	// Geofence policy of the zone, HOLD, REROUTE or CONTINUE
+	Policy string `json:"policy"`
 }
 
 type ActivationTime struct {
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// ResourceState is the lifecycle state of an emulated resource
+type ResourceState string
+
+const (
+	StateIdle             ResourceState = "IDLE"
+	StateCharging         ResourceState = "CHARGING"
+	StatePatrol           ResourceState = "PATROL"
+	StatePendingClearance ResourceState = "PENDING_CLEARANCE"
+	StateDispatching      ResourceState = "DISPATCHING"
+	StateEnRoute          ResourceState = "EN_ROUTE"
+	StateOnScene          ResourceState = "ON_SCENE"
+	StateReturning        ResourceState = "RETURNING"
+	StateLanded           ResourceState = "LANDED"
+	StateFault            ResourceState = "FAULT"
+)
+
+// StateTransition records a change of state of a resource
+type StateTransition struct {
+	From      ResourceState `json:"from"`
+	To        ResourceState `json:"to"`
+	Reason    string        `json:"reason"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+// ResourceStateInfo is the current state of a resource with its latest transitions
+type ResourceStateInfo struct {
+	ResourceId  string            `json:"resourceId"`
+	State       ResourceState     `json:"state"`
+	Since       time.Time         `json:"since"`
+	MissionId   string            `json:"missionId,omitempty"`
+	Progress    *MissionProgress  `json:"progress,omitempty"`
+	Transitions []StateTransition `json:"transitions"`
+}
+
+// MissionProgress is the progress of a resource along the waypoints of its mission
+type MissionProgress struct {
+	CurrentWaypointIndex    int     `json:"currentWaypointIndex"`
+	WaypointCount           int     `json:"waypointCount"`
+	OnComplete              string  `json:"onComplete"`
+	Loops                   int     `json:"loops"`
+	DistanceRemainingMeters float64 `json:"distanceRemainingMeters"`
+	EtaSeconds              float64 `json:"etaSeconds"`
+}
@@ -0,0 +1,28 @@
+package models
+
+const (
+	RouteTypeFastest  = "fastest"
+	RouteTypeShortest = "shortest"
+	RouteTypeAvoid    = "avoid"
+)
+
+const (
+	TravelModeDrone   = "drone"
+	TravelModeVehicle = "vehicle"
+)
+
+const (
+	RouteRepresentationPolyline    = "polyline"
+	RouteRepresentationSummaryOnly = "summaryOnly"
+	RouteRepresentationNone        = "none"
+)
+
+// RouteRequest holds the query parameters of a route request, empty values take the defaults
+type RouteRequest struct {
+	Query               string
+	RouteType           string
+	TravelMode          string
+	ComputeBestOrder    string
+	RouteRepresentation string
+	ResourceId          string
+}
@@ -4,13 +4,24 @@ import "time"
 
 // RouteResponse represents the entire response containing routes.
 type RouteResponse struct {
-	Routes []Route `json:"routes"`
+	Routes             []Route             `json:"routes"`
+	OptimizedWaypoints []OptimizedWaypoint `json:"optimizedWaypoints,omitempty"`
+	// Version of the no-fly zones the route was computed against, stale when they could not be refreshed
+	ZoneSnapshotVersion int64 `json:"zoneSnapshotVersion"`
+	ZoneSnapshotStale   bool  `json:"zoneSnapshotStale"`
+}
+
+// OptimizedWaypoint maps an intermediate stop of the query to its position in the optimized route.
+// The indexes count the intermediate stops only, from 0.
+type OptimizedWaypoint struct {
+	ProvidedIndex  int `json:"providedIndex"`
+	OptimizedIndex int `json:"optimizedIndex"`
 }
 
 // Route contains summary information and legs of a route.
 type Route struct {
 	Summary RouteSummary `json:"summary"`
-	Legs    []Leg        `json:"legs"`
+	Legs    []Leg        `json:"legs,omitempty"`
 }
 
 // RouteSummary contains information summarizing the route.
@@ -29,7 +40,7 @@ type RouteSummary struct {
 // Leg represents each leg of the journey with its own summary and points.
 type Leg struct {
 	Summary LegSummary `json:"summary"`
-	Points  []Point    `json:"points"`
+	Points  []Point    `json:"points,omitempty"`
 }
 
 // LegSummary contains information summarizing a leg of a route.
@@ -47,9 +58,18 @@ type LegSummary struct {
 
 // ClearanceZone represents a no-fly zone with entry and exit times.
 type ClearanceZone struct {
-	ID        string    `json:"id"`
-	EntryTime time.Time `json:"entryTime"`
-	ExitTime  time.Time `json:"exitTime"`
+	ID             string         `json:"id"`
+	EntryTime      time.Time      `json:"entryTime"`
+	ExitTime       time.Time      `json:"exitTime"`
+	DistanceInZone float64        `json:"distanceInZoneInMeters"`
+	Crossings      []ZoneCrossing `json:"crossings"`
+}
+
+// ZoneCrossing represents one passage through a no-fly zone, from its entry point to its exit point.
+type ZoneCrossing struct {
+	Entry          Point   `json:"entry"`
+	Exit           Point   `json:"exit"`
+	DistanceInZone float64 `json:"distanceInZoneInMeters"`
 }
 
 // Point represents a specific point on the map with latitude, longitude, altitude, and time.
@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// Scenario holds the run settings shipped along the resources file
+type Scenario struct {
+	// Seed of every random value of the run, overridden by the seed option
+	Seed *int64 `json:"seed"`
+	// StartTime is the initial time of the simulation clock, the current time by default
+	StartTime *time.Time `json:"startTime"`
+	// Resources is the fleet of the run, the resources file is used when empty
+	Resources []Resource `json:"resources"`
+	// Dbxs are the DBXs owning the drones, three DBXs sharing the fleet by default
+	Dbxs []DroneBox `json:"dbxs"`
+	// Zones are local no-fly zones created at startup, replacing the local zones of the same id
+	Zones []Zone `json:"zones"`
+	// Events is the timeline run against the simulation clock
+	Events []ScenarioEvent `json:"events"`
+}
+
+// Scenario event actions
+const (
+	ScenarioStartMission   = "START_MISSION"
+	ScenarioStopMission    = "STOP_MISSION"
+	ScenarioAddZone        = "ADD_ZONE"
+	ScenarioActivateZone   = "ACTIVATE_ZONE"
+	ScenarioDeactivateZone = "DEACTIVATE_ZONE"
+	ScenarioRemoveZone     = "REMOVE_ZONE"
+	ScenarioGrantClearance = "GRANT_CLEARANCE"
+	ScenarioDenyClearance  = "DENY_CLEARANCE"
+	ScenarioSetBattery     = "SET_BATTERY"
+	ScenarioLoseGps        = "LOSE_GPS"
+	ScenarioRestoreGps     = "RESTORE_GPS"
+	ScenarioFault          = "FAULT"
+)
+
+// ScenarioEvent is an action of the timeline, run at a number of seconds after the start of the simulation clock.
+// The fields used depend on the action:
+// START_MISSION uses the mission, its resource defaulting to the resourceId of the event,
+// STOP_MISSION, SET_BATTERY, LOSE_GPS and RESTORE_GPS use the resourceId, FAULT uses the resourceId and the fault,
+// ADD_ZONE uses the zone, the other zone actions use the zoneId,
+// GRANT_CLEARANCE and DENY_CLEARANCE use the missionId and the zoneId.
+type ScenarioEvent struct {
+	At           float64         `json:"at"`
+	Action       string          `json:"action"`
+	ResourceId   string          `json:"resourceId,omitempty"`
+	ZoneId       string          `json:"zoneId,omitempty"`
+	MissionId    string          `json:"missionId,omitempty"`
+	Mission      *MissionCommand `json:"mission,omitempty"`
+	Zone         *Zone           `json:"zone,omitempty"`
+	Fault        *FaultCommand   `json:"fault,omitempty"`
+	BatteryLevel *float64        `json:"batteryLevel,omitempty"`
+}
+
+// Status of a scenario event
+const (
+	ScenarioEventPending = "PENDING"
+	ScenarioEventDone    = "DONE"
+	ScenarioEventFailed  = "FAILED"
+)
+
+// ScenarioEventStatus is the outcome of a timeline event
+type ScenarioEventStatus struct {
+	ScenarioEvent
+	Time   time.Time `json:"time"`
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// ScenarioStatus is the progress of the timeline of the run
+type ScenarioStatus struct {
+	StartTime time.Time             `json:"startTime"`
+	Events    []ScenarioEventStatus `json:"events"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// SimClockStatus is the state of the simulation clock
+type SimClockStatus struct {
+	Now           time.Time `json:"now"`
+	Speed         float64   `json:"speed"`
+	Paused        bool      `json:"paused"`
+	PendingTimers int       `json:"pendingTimers"`
+	Seed          int64     `json:"seed"`
+}
+
+// SimClockCommand holds the parameters of the clock speed and step commands
+type SimClockCommand struct {
+	Speed   *float64 `json:"speed"`
+	Seconds *float64 `json:"seconds"`
+}
@@ -0,0 +1,28 @@
+package models
+
+const (
+	StreamEventLocation = "location"
+	StreamEventStatus   = "status"
+	StreamEventState    = "state"
+	StreamEventGeofence = "geofence"
+)
+
+// StreamEvent is a live update pushed to the stream clients
+type StreamEvent struct {
+	Type         string            `json:"type"`
+	ResourceId   string            `json:"resourceId"`
+	ResourceType string            `json:"resourceType"`
+	TimestampMs  int64             `json:"timestampMs"`
+	Location     *ResourceLocation `json:"location,omitempty"`
+	Status       *DroneStatus      `json:"status,omitempty"`
+	Transition   *StateTransition  `json:"transition,omitempty"`
+	MissionId    string            `json:"missionId,omitempty"`
+	Geofence     *GeofenceEvent    `json:"geofence,omitempty"`
+}
+
+// StreamFilter selects the events sent to a stream client, an empty list matches everything
+type StreamFilter struct {
+	ResourceIds   []string
+	ResourceTypes []string
+	EventTypes    []string
+}
@@ -0,0 +1,12 @@
+package models
+
+// TelemetrySinkStats holds the delivery counters of a telemetry sink
+type TelemetrySinkStats struct {
+	Sink          string `json:"sink"`
+	QueueLength   int    `json:"queueLength"`
+	QueueCapacity int    `json:"queueCapacity"`
+	Sent          int64  `json:"sent"`
+	Retried       int64  `json:"retried"`
+	Failed        int64  `json:"failed"`
+	Dropped       int64  `json:"dropped"`
+}
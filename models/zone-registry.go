@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// ZoneRegistryStatus is the state of the cached no-fly zones
+type ZoneRegistryStatus struct {
+	Version                int64      `json:"version"`
+	ZoneCount              int        `json:"zoneCount"`
+	LocalZoneCount         int        `json:"localZoneCount"`
+	UpdatedAt              *time.Time `json:"updatedAt,omitempty"`
+	AgeSeconds             float64    `json:"ageSeconds"`
+	Stale                  bool       `json:"stale"`
+	LastAttemptAt          *time.Time `json:"lastAttemptAt,omitempty"`
+	LastError              string     `json:"lastError,omitempty"`
+	RefreshIntervalSeconds float64    `json:"refreshIntervalSeconds"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Zone is a no-fly zone managed locally by the emulator.
+// The geometry is a GeoJSON Polygon, MultiPolygon or Point, a Point being the center of a circle of the given radius in meters.
+// Without activation times the zone is always active. The policy tells how resources in flight deal with the zone,
+// HOLD, REROUTE or CONTINUE, the default geofence policy if empty.
+type Zone struct {
+	ID              string     `json:"id"`
+	Name            string     `json:"name,omitempty"`
+	Geometry        Geometry   `json:"geometry"`
+	Radius          float64    `json:"radius,omitempty"`
+	ActivationStart *time.Time `json:"activationStart,omitempty"`
+	ActivationEnd   *time.Time `json:"activationEnd,omitempty"`
+	Policy          string     `json:"policy,omitempty"`
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	commonS3 "gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/s3"
+
+	"h3d-drone-emulator/config"
+)
+
+const (
+	AssetSourceS3       = "s3"
+	AssetSourceLocal    = "local"
+	AssetSourceEmbedded = "embedded"
+)
+
+// ErrAssetNotFound is returned when the asset source has no file of the requested name
+var ErrAssetNotFound = errors.New("asset not found")
+
+// Default scenario shipped with the binary, used when no object store is available
+//
+//go:embed assets/default
+var embeddedAssets embed.FS
+
+// AssetSource gives access to the resources file and the <resourceId>.csv simulation files
+type AssetSource interface {
+	ReadJSON(name string, v interface{}) error
+	ReadBytes(name string) ([]byte, error)
+	String() string
+}
+
+// s3AssetSource reads assets from a S3 bucket
+type s3AssetSource struct {
+	client *s3.S3
+	bucket string
+}
+
+func (a *s3AssetSource) ReadJSON(name string, v interface{}) error {
+	return s3AssetError(name, commonS3.ReadJsonFile(a.client, a.bucket, name, v))
+}
+
+func (a *s3AssetSource) ReadBytes(name string) ([]byte, error) {
+	data, err := commonS3.ReadBytes(a.client, a.bucket, name)
+	return data, s3AssetError(name, err)
+}
+
+// s3AssetError marks the missing objects as ErrAssetNotFound
+func s3AssetError(name string, err error) error {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == s3.ErrCodeNoSuchKey {
+		return fmt.Errorf("%w: %s: %s", ErrAssetNotFound, name, err)
+	}
+	return err
+}
+
+func (a *s3AssetSource) String() string {
+	return "s3://" + a.bucket
+}
+
+// fsAssetSource reads assets from a file system, either a local directory or the embedded scenario
+type fsAssetSource struct {
+	fsys fs.FS
+	name string
+}
+
+func (a *fsAssetSource) ReadJSON(name string, v interface{}) error {
+	data, err := a.ReadBytes(name)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (a *fsAssetSource) ReadBytes(name string) ([]byte, error) {
+	data, err := fs.ReadFile(a.fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrAssetNotFound, err)
+	}
+	return data, err
+}
+
+func (a *fsAssetSource) String() string {
+	return a.name
+}
+
+// NewAssetSource creates the asset source selected by the asset-source option
+func NewAssetSource(appConfig config.AppConfig) (AssetSource, error) {
+	switch *appConfig.AssetSource {
+	case AssetSourceS3:
+		client, err := commonS3.CreateClient(appConfig.S3Config)
+		if err != nil {
+			return nil, fmt.Errorf("could not create S3 client: %w", err)
+		}
+		if client == nil {
+			return nil, fmt.Errorf("could not create S3 client")
+		}
+		return &s3AssetSource{client: client, bucket: *appConfig.S3Bucket}, nil
+	case AssetSourceLocal:
+		info, err := os.Stat(*appConfig.AssetDir)
+		if err != nil {
+			return nil, fmt.Errorf("could not open asset directory: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("asset directory %s is not a directory", *appConfig.AssetDir)
+		}
+		return &fsAssetSource{fsys: os.DirFS(*appConfig.AssetDir), name: "dir://" + *appConfig.AssetDir}, nil
+	case AssetSourceEmbedded:
+		sub, err := fs.Sub(embeddedAssets, "assets/default")
+		if err != nil {
+			return nil, err
+		}
+		return &fsAssetSource{fsys: sub, name: "embedded://default"}, nil
+	}
+	return nil, fmt.Errorf("unknown asset source: %s", *appConfig.AssetSource)
+}
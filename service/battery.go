@@ -0,0 +1,200 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
+
+	"h3d-drone-emulator/models"
+	restrictedZone "h3d-drone-emulator/util"
+)
+
+// ErrNotRecoverable is returned when recovering a resource which is not down away from its base
+var ErrNotRecoverable = errors.New("resource cannot be recovered")
+
+// baseTolerance is the distance in meters under which a drone is considered at its base
+const baseTolerance = 10.0
+
+// batteryReserveMargin is the margin applied to the energy needed to fly back to base
+const batteryReserveMargin = 1.2
+
+// batteryModel computes the consumption of a drone, in percent of its capacity per second
+type batteryModel struct {
+	// cruiseDrain is the consumption at cruise speed in level flight, without wind nor payload
+	cruiseDrain   float64
+	cruiseSpeed   float64
+	hoverFactor   float64
+	climbFactor   float64
+	payloadFactor float64
+	// windEast and windNorth are the components of the wind velocity in meters per second
+	windEast  float64
+	windNorth float64
+}
+
+// getBatteryModel returns the configured battery model of the drones
+func getBatteryModel() batteryModel {
+	mass := math.Max(*applicationConfig.DroneMass, 0.1)
+	// The wind direction is where it blows from
+	windDirection := *applicationConfig.WindDirection * math.Pi / 180
+	return batteryModel{
+		cruiseDrain: float64(100) / (*applicationConfig.BatteryLife * 60),
+		cruiseSpeed: getMotionProfile(false, 0).CruiseSpeed,
+		hoverFactor: *applicationConfig.BatteryHoverFactor,
+		climbFactor: *applicationConfig.BatteryClimbFactor,
+		// The power needed to stay airborne grows with the mass to the power of 1.5
+		payloadFactor: math.Pow((mass+math.Max(*applicationConfig.Payload, 0))/mass, 1.5),
+		windEast:      -*applicationConfig.WindSpeed * math.Sin(windDirection),
+		windNorth:     -*applicationConfig.WindSpeed * math.Cos(windDirection),
+	}
+}
+
+// drain returns the consumption of a drone flying with the motion state.
+// The consumption goes from the hover factor at rest to 1 at cruise airspeed and grows with the square of the airspeed beyond,
+// climbing adds to it and the payload multiplies it.
+func (b batteryModel) drain(motion kinematicState) float64 {
+	heading := motion.Heading * math.Pi / 180
+	airspeed := math.Hypot(motion.GroundSpeed*math.Sin(heading)-b.windEast, motion.GroundSpeed*math.Cos(heading)-b.windNorth)
+	ratio := 0.0
+	if b.cruiseSpeed > 0 {
+		ratio = airspeed / b.cruiseSpeed
+	}
+	factor := ratio * ratio
+	if ratio <= 1 {
+		factor = b.hoverFactor + (1-b.hoverFactor)*ratio
+	}
+	factor += b.climbFactor * math.Max(motion.VerticalSpeed, 0)
+	return b.cruiseDrain * factor * b.payloadFactor
+}
+
+// cruiseDrainToward returns the consumption at cruise speed toward the heading
+func (b batteryModel) cruiseDrainToward(heading float64) float64 {
+	return b.drain(kinematicState{GroundSpeed: b.cruiseSpeed, Heading: heading})
+}
+
+// getChargeRate returns the charge rate at the battery level in percent per second,
+// constant up to the taper level then decreasing linearly down to a tenth near full charge
+func getChargeRate(level float64) float64 {
+	rate := *applicationConfig.BatteryChargeRate / 60
+	taper := *applicationConfig.BatteryChargeTaper
+	if level > taper && taper < 100 {
+		rate *= math.Max(0.1, (100-level)/(100-taper))
+	}
+	return rate
+}
+
+// isAtBase tells whether the drone is on the ground at its base
+func isAtBase(drone models.DroneH3D) bool {
+	home := restrictedZone.Point{Lat: drone.HomeLat, Lon: drone.HomeLong}
+	return drone.CurrAltitude <= 0 && restrictedZone.DistanceMeters(restrictedZone.Point{Lat: drone.CurrLat, Lon: drone.CurrLong}, home) <= baseTolerance
+}
+
+// canCharge tells whether a drone at base in the state is charged
+func canCharge(state models.ResourceState) bool {
+	return state == models.StateIdle || state == models.StateLanded || state == models.StateCharging
+}
+
+// getReturnLevel returns the battery level under which the drone returns to base,
+// the configured level raised to keep the energy needed to fly back and land with a margin
+func getReturnLevel(drone models.DroneH3D) float64 {
+	model := getBatteryModel()
+	position := restrictedZone.Point{Lat: drone.CurrLat, Lon: drone.CurrLong}
+	home := restrictedZone.Point{Lat: drone.HomeLat, Lon: drone.HomeLong}
+	needed := 0.0
+	if distance := restrictedZone.DistanceMeters(position, home); distance > 0 && model.cruiseSpeed > 0 {
+		needed += distance / model.cruiseSpeed * model.cruiseDrainToward(restrictedZone.InitialBearing(position, home))
+	}
+	// The drone climbs to cruise altitude, flies back then lands
+	if climbRate := *applicationConfig.ClimbRate; climbRate > 0 {
+		altitude := drone.CurrAltitude * restrictedZone.FeetToMeters
+		cruiseAltitude := math.Max(altitude, float64(*applicationConfig.Altitude)*restrictedZone.FeetToMeters)
+		needed += (cruiseAltitude - altitude) / climbRate * model.drain(kinematicState{VerticalSpeed: climbRate})
+		needed += cruiseAltitude / climbRate * model.drain(kinematicState{})
+	}
+	return math.Max(*applicationConfig.BatteryRtlLevel, *applicationConfig.BatteryLandLevel+needed*batteryReserveMargin)
+}
+
+// updateBattery drains or charges the battery of a drone during the elapsed seconds.
+// A drone on the ground at base is charged, a drone low on battery returns to base and an empty one lands where it is.
+func updateBattery(droneId string, elapsed float64) (models.DroneH3D, bool) {
+	state := fleet.CurrentState(droneId)
+	motion := fleet.Motion(droneId)
+	model := getBatteryModel()
+	charging := false
+	drone, found := fleet.UpdateDrone(droneId, func(drone *models.DroneH3D) {
+		switch {
+		case isAtBase(*drone) && canCharge(state):
+			charging = true
+			drone.BattLevel = math.Min(100, drone.BattLevel+elapsed*getChargeRate(drone.BattLevel))
+		case state == models.StatePatrol:
+			// Patrol moves are not simulated, the drone is considered cruising toward its heading
+			drone.BattLevel = math.Max(0, drone.BattLevel-elapsed*model.cruiseDrainToward(drone.CurrHeading))
+		case drone.CurrAltitude > 0 || motion.GroundSpeed > 0:
+			drone.BattLevel = math.Max(0, drone.BattLevel-elapsed*model.drain(motion))
+		}
+	})
+	if !found {
+		return drone, false
+	}
+
+	switch {
+	case charging && drone.BattLevel < 100 && state != models.StateCharging:
+		fleet.Transition(droneId, models.StateCharging, "charging at base")
+	case charging && drone.BattLevel >= 100 && state == models.StateCharging:
+		if len(fleet.Patrol(droneId)) > 0 {
+			fleet.Transition(droneId, models.StatePatrol, "charged, resuming patrol")
+		} else {
+			fleet.Transition(droneId, models.StateIdle, "charged")
+		}
+	case charging || isAtBase(drone):
+		// Drones on the ground at base do not fly on their battery
+	case drone.BattLevel <= *applicationConfig.BatteryLandLevel && (isOnMission(state) || state == models.StatePatrol || state == models.StateReturning):
+		log.Info("%s battery depleted at %.1f%%, landing in place", droneId, drone.BattLevel)
+		forceLanding(droneId, "battery depleted, landing in place")
+	case drone.BattLevel <= getReturnLevel(drone) && (isOnMission(state) || state == models.StatePatrol):
+		log.Info("%s battery low at %.1f%%, returning to base", droneId, drone.BattLevel)
+		forceReturnToBase(droneId, "low battery")
+	}
+	return drone, true
+}
+
+// RecoverResource brings a drone down away from its base, landed by a depleted battery or on a fault, back to its base
+// on the ground, as a field team would. It then charges there and resumes its patrol, if any.
+func RecoverResource(resourceId string) (models.ResourceStateInfo, error) {
+	drone, found := fleet.Drone(resourceId)
+	if !found {
+		if fleet.Exists(resourceId) {
+			return models.ResourceStateInfo{}, fmt.Errorf("%w: %s is not a drone", ErrNotRecoverable, resourceId)
+		}
+		return models.ResourceStateInfo{}, ErrResourceNotFound
+	}
+	state := fleet.CurrentState(resourceId)
+	switch {
+	case state != models.StateLanded && state != models.StateFault:
+		return models.ResourceStateInfo{}, fmt.Errorf("%w: %s is %s", ErrNotRecoverable, resourceId, state)
+	case state == models.StateLanded && isAtBase(drone):
+		return models.ResourceStateInfo{}, fmt.Errorf("%w: %s is already at base", ErrNotRecoverable, resourceId)
+	case faults.has(resourceId, models.FaultMotorFailure):
+		return models.ResourceStateInfo{}, fmt.Errorf("%w: the motor failure of %s must be cleared first", ErrNotRecoverable, resourceId)
+	}
+
+	// Stops the landing in progress, if any, then carries the drone home without flying it through the zones
+	fleet.StartActivity(resourceId)
+	fleet.SetMotion(resourceId, kinematicState{Position: restrictedZone.Point{Lat: drone.HomeLat, Lon: drone.HomeLong}, Heading: drone.CurrHeading})
+	fleet.UpdateLocation(resourceId, drone.HomeLat, drone.HomeLong)
+	fleet.UpdateDrone(resourceId, func(drone *models.DroneH3D) {
+		drone.CurrAltitude = 0
+	})
+	if state == models.StateFault {
+		if err := fleet.Transition(resourceId, models.StateLanded, "recovered"); err != nil {
+			return models.ResourceStateInfo{}, err
+		}
+	}
+	if err := fleet.Transition(resourceId, models.StateCharging, "recovered to base"); err != nil {
+		return models.ResourceStateInfo{}, err
+	}
+	log.Info("%s recovered to base", resourceId)
+	publishLocation(resourceId, false)
+	return GetResourceState(resourceId)
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
+
+	"h3d-drone-emulator/models"
+	restrictedZone "h3d-drone-emulator/util"
+)
+
+var (
+	// ErrClearanceNotFound is returned when a mission has no clearance
+	ErrClearanceNotFound = errors.New("clearance not found")
+	// ErrClearanceDecided is returned when deciding a clearance which is already granted or denied
+	ErrClearanceDecided = errors.New("clearance already decided")
+	// ErrClearanceDenied is returned when starting a mission whose clearance is denied
+	ErrClearanceDenied = errors.New("clearance denied")
+)
+
+// missionClearance is the clearance of a mission, changed is closed on every decision
+type missionClearance struct {
+	models.MissionClearance
+	changed chan struct{}
+}
+
+// zoneClearanceStats accumulates the decisions of a zone
+type zoneClearanceStats struct {
+	granted      int
+	denied       int
+	observed     int
+	totalSeconds float64
+}
+
+// clearanceStore holds the clearances of the missions and the observed times to clearance
+type clearanceStore struct {
+	mutex      sync.Mutex
+	clearances map[string]*missionClearance
+	stats      map[string]*zoneClearanceStats
+}
+
+var clearances = &clearanceStore{clearances: make(map[string]*missionClearance), stats: make(map[string]*zoneClearanceStats)}
+
+// getLocked returns the clearance of the mission, created empty if needed, the caller must hold the lock
+func (s *clearanceStore) getLocked(missionId string) *missionClearance {
+	clearance, found := s.clearances[missionId]
+	if !found {
+		clearance = &missionClearance{
+			MissionClearance: models.MissionClearance{MissionId: missionId, Status: models.ClearanceGranted, Zones: []models.ZoneClearance{}},
+			changed:          make(chan struct{}),
+		}
+		s.clearances[missionId] = clearance
+	}
+	return clearance
+}
+
+// request asks for the clearance of the zones crossed by a mission, the zones already decided keep their decision
+func (s *clearanceStore) request(missionId string, resourceId string, crossed []models.ClearanceZone, now time.Time) models.MissionClearance {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	clearance := s.getLocked(missionId)
+	clearance.ResourceId = resourceId
+	for _, zone := range crossed {
+		zoneClearance := findZoneClearance(clearance, zone.ID)
+		if zoneClearance == nil {
+			clearance.Zones = append(clearance.Zones, models.ZoneClearance{ZoneId: zone.ID, Status: models.ClearancePending})
+			zoneClearance = &clearance.Zones[len(clearance.Zones)-1]
+		}
+		entryTime, exitTime := zone.EntryTime, zone.ExitTime
+		zoneClearance.EntryTime, zoneClearance.ExitTime = &entryTime, &exitTime
+		if zoneClearance.Status == models.ClearancePending && zoneClearance.RequestedAt == nil {
+			requestedAt := now
+			zoneClearance.RequestedAt = &requestedAt
+		}
+	}
+	clearance.Status = getClearanceStatus(clearance.Zones)
+	return copyClearance(clearance)
+}
+
+// decide grants or denies the clearance of a mission for a zone.
+// Deciding before the mission starts clears the zone in advance.
+func (s *clearanceStore) decide(missionId string, zoneId string, status string, now time.Time) (models.MissionClearance, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	clearance := s.getLocked(missionId)
+	zoneClearance := findZoneClearance(clearance, zoneId)
+	if zoneClearance == nil {
+		clearance.Zones = append(clearance.Zones, models.ZoneClearance{ZoneId: zoneId, Status: models.ClearancePending})
+		zoneClearance = &clearance.Zones[len(clearance.Zones)-1]
+	}
+	if zoneClearance.Status != models.ClearancePending {
+		return models.MissionClearance{}, fmt.Errorf("%w: zone %s of mission %s is %s", ErrClearanceDecided, zoneId, missionId, zoneClearance.Status)
+	}
+	decidedAt := now
+	zoneClearance.Status = status
+	zoneClearance.DecidedAt = &decidedAt
+
+	stats, found := s.stats[zoneId]
+	if !found {
+		stats = &zoneClearanceStats{}
+		s.stats[zoneId] = stats
+	}
+	if status == models.ClearanceDenied {
+		stats.denied++
+	} else {
+		stats.granted++
+		if zoneClearance.RequestedAt != nil {
+			timeToClearance := now.Sub(*zoneClearance.RequestedAt).Seconds()
+			zoneClearance.TimeToClearanceSeconds = &timeToClearance
+			stats.observed++
+			stats.totalSeconds += timeToClearance
+		}
+	}
+	log.Info("Clearance of mission %s for zone %s %s", missionId, zoneId, status)
+
+	clearance.Status = getClearanceStatus(clearance.Zones)
+	close(clearance.changed)
+	clearance.changed = make(chan struct{})
+	return copyClearance(clearance), nil
+}
+
+// watch returns the status of the clearance of a mission and a channel closed on its next change
+func (s *clearanceStore) watch(missionId string) (string, <-chan struct{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	clearance := s.getLocked(missionId)
+	return clearance.Status, clearance.changed
+}
+
+// isGranted tells whether a mission is cleared to enter a zone
+func (s *clearanceStore) isGranted(missionId string, zoneId string) bool {
+	if missionId == "" {
+		return false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	clearance, found := s.clearances[missionId]
+	if !found {
+		return false
+	}
+	zoneClearance := findZoneClearance(clearance, zoneId)
+	return zoneClearance != nil && zoneClearance.Status == models.ClearanceGranted
+}
+
+// clearanceTime returns the expected time to clearance of a zone in seconds: the average observed for the zone,
+// else the average observed for all zones, else the configured clearance time
+func (s *clearanceStore) clearanceTime(zoneId string) float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if stats, found := s.stats[zoneId]; found && stats.observed > 0 {
+		return stats.totalSeconds / float64(stats.observed)
+	}
+	observed, totalSeconds := 0, 0.0
+	for _, stats := range s.stats {
+		observed += stats.observed
+		totalSeconds += stats.totalSeconds
+	}
+	if observed > 0 {
+		return totalSeconds / float64(observed)
+	}
+	return float64(*applicationConfig.ClearanceTime)
+}
+
+func (s *clearanceStore) statistics() models.ClearanceStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	result := models.ClearanceStats{Zones: make([]models.ZoneClearanceStats, 0, len(s.stats))}
+	observed, totalSeconds := 0, 0.0
+	for zoneId, stats := range s.stats {
+		zoneStats := models.ZoneClearanceStats{ZoneId: zoneId, Granted: stats.granted, Denied: stats.denied}
+		if stats.observed > 0 {
+			average := stats.totalSeconds / float64(stats.observed)
+			zoneStats.AverageTimeToClearanceSeconds = &average
+		}
+		result.Zones = append(result.Zones, zoneStats)
+		result.Granted += stats.granted
+		result.Denied += stats.denied
+		observed += stats.observed
+		totalSeconds += stats.totalSeconds
+	}
+	if observed > 0 {
+		average := totalSeconds / float64(observed)
+		result.AverageTimeToClearanceSeconds = &average
+	}
+	sort.Slice(result.Zones, func(i, j int) bool {
+		return result.Zones[i].ZoneId < result.Zones[j].ZoneId
+	})
+	return result
+}
+
+func findZoneClearance(clearance *missionClearance, zoneId string) *models.ZoneClearance {
+	for i := range clearance.Zones {
+		if clearance.Zones[i].ZoneId == zoneId {
+			return &clearance.Zones[i]
+		}
+	}
+	return nil
+}
+
+// getClearanceStatus returns DENIED if a zone is denied, PENDING if a zone is still pending, GRANTED otherwise
+func getClearanceStatus(zones []models.ZoneClearance) string {
+	status := models.ClearanceGranted
+	for _, zone := range zones {
+		if zone.Status == models.ClearanceDenied {
+			return models.ClearanceDenied
+		}
+		if zone.Status == models.ClearancePending {
+			status = models.ClearancePending
+		}
+	}
+	return status
+}
+
+func copyClearance(clearance *missionClearance) models.MissionClearance {
+	result := clearance.MissionClearance
+	result.Zones = append([]models.ZoneClearance{}, clearance.Zones...)
+	return result
+}
+
+// getClearanceTime returns the time in seconds to wait for the clearance of the zones, cleared in parallel
+func getClearanceTime(zones []models.ClearanceZone) float64 {
+	clearanceTime := 0.0
+	for _, zone := range zones {
+		if t := clearances.clearanceTime(zone.ID); t > clearanceTime {
+			clearanceTime = t
+		}
+	}
+	return clearanceTime
+}
+
+// getMissionClearanceZones returns the active zones crossed by a drone flying through the waypoints from its position
+func getMissionClearanceZones(res models.Resource, waypoints []models.MissionWaypoint) []models.ClearanceZone {
+	path := []restrictedZone.Point{{Lat: res.Latitude, Lon: res.Longitude}}
+	for _, waypoint := range waypoints {
+		path = append(path, restrictedZone.Point{Lat: waypoint.Latitude, Lon: waypoint.Longitude})
+	}
+	departure := simClock.Now()
+	speed := float64(*applicationConfig.DroneSpeed)
+	horizon := 3 * getDirectFlightTime(path, speed)
+	active := getActiveZones(zones.snapshot().zones, departure, departure.Add(time.Duration(horizon)*time.Second))
+	return planPath(path, active, departure, speed).clearanceZones
+}
+
+// waitForClearance blocks until the clearance of the mission is decided, it returns false if the mission is stopped
+func waitForClearance(missionId string, stop <-chan struct{}) (string, bool) {
+	for {
+		status, changed := clearances.watch(missionId)
+		if status != models.ClearancePending {
+			return status, true
+		}
+		select {
+		case <-stop:
+			return "", false
+		case <-changed:
+		}
+	}
+}
+
+// GetMissionClearance returns the clearance of a mission
+func GetMissionClearance(missionId string) (models.MissionClearance, error) {
+	clearances.mutex.Lock()
+	defer clearances.mutex.Unlock()
+	clearance, found := clearances.clearances[missionId]
+	if !found {
+		return models.MissionClearance{}, ErrClearanceNotFound
+	}
+	return copyClearance(clearance), nil
+}
+
+// GrantClearance clears a mission to enter a zone
+func GrantClearance(missionId string, zoneId string) (models.MissionClearance, error) {
+	return clearances.decide(missionId, zoneId, models.ClearanceGranted, simClock.Now())
+}
+
+// DenyClearance refuses a mission to enter a zone, the mission is aborted
+func DenyClearance(missionId string, zoneId string) (models.MissionClearance, error) {
+	return clearances.decide(missionId, zoneId, models.ClearanceDenied, simClock.Now())
+}
+
+// GetClearanceStats returns the observed times to clearance
+func GetClearanceStats() models.ClearanceStats {
+	return clearances.statistics()
+}
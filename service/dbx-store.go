@@ -0,0 +1,113 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+
+	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
+
+	"h3d-drone-emulator/models"
+)
+
+// ErrDbxNotFound is returned when a request targets an unknown DBX
+var ErrDbxNotFound = errors.New("dbx not found")
+
+// defaultDbxIds are the DBXs of the fleet when the scenario defines none
+var defaultDbxIds = []string{"603f1c1dbf35eba727ee6c3a", "602c8187519cc032dd65e739", "604743f99ecead5820683080"}
+
+// dbxStore holds the DBXs and the drones each of them owns, fixed for the run so read without lock
+type dbxStore struct {
+	ids       []string
+	boxes     map[string]models.DroneBox
+	owners    map[string]string
+	createdAt int64
+}
+
+var dbxs *dbxStore
+
+// newDbxStore creates the DBXs of the fleet, the default ones when the scenario defines no valid DBX.
+// The drones listed by no DBX are spread over the DBXs in turn, and a DBX without position stands at the base of its first drone.
+// createdAt is the creation time in milliseconds reported for the drones.
+func newDbxStore(boxes []models.DroneBox, resources []models.Resource, createdAt int64) *dbxStore {
+	s := &dbxStore{boxes: make(map[string]models.DroneBox), owners: make(map[string]string), createdAt: createdAt}
+	if len(boxes) == 0 {
+		for i, id := range defaultDbxIds {
+			boxes = append(boxes, models.DroneBox{ID: id, Name: "H3d DBX " + strconv.Itoa(i+1), SerialNo: "DBX00" + strconv.Itoa(i+1)})
+		}
+	}
+	drones := make(map[string]models.Resource)
+	for _, res := range resources {
+		if res.Type == "DRONE" {
+			drones[res.ID] = res
+		}
+	}
+	for _, box := range boxes {
+		if _, found := s.boxes[box.ID]; found || box.ID == "" {
+			log.Error("Skipping DBX with a missing or duplicate id %q", box.ID)
+			continue
+		}
+		owned := make([]string, 0, len(box.DroneIds))
+		for _, droneId := range box.DroneIds {
+			if _, found := drones[droneId]; !found {
+				log.Error("Skipping unknown drone %s of DBX %s", droneId, box.ID)
+				continue
+			}
+			if owner, found := s.owners[droneId]; found {
+				log.Error("Skipping drone %s of DBX %s, already owned by DBX %s", droneId, box.ID, owner)
+				continue
+			}
+			s.owners[droneId] = box.ID
+			owned = append(owned, droneId)
+		}
+		box.DroneIds = owned
+		s.ids = append(s.ids, box.ID)
+		s.boxes[box.ID] = box
+	}
+	if len(s.ids) == 0 {
+		log.Error("No valid DBX in the scenario, using the default DBXs")
+		return newDbxStore(nil, resources, createdAt)
+	}
+	next := 0
+	for _, res := range resources {
+		if _, found := drones[res.ID]; !found {
+			continue
+		}
+		if _, found := s.owners[res.ID]; found {
+			continue
+		}
+		id := s.ids[next%len(s.ids)]
+		next++
+		box := s.boxes[id]
+		box.DroneIds = append(box.DroneIds, res.ID)
+		s.boxes[id] = box
+		s.owners[res.ID] = id
+	}
+	for _, id := range s.ids {
+		box := s.boxes[id]
+		if box.Latitude == 0 && box.Longitude == 0 && len(box.DroneIds) > 0 {
+			base := drones[box.DroneIds[0]]
+			box.Latitude, box.Longitude = base.BaseLatitude, base.BaseLongitude
+			s.boxes[id] = box
+		}
+	}
+	return s
+}
+
+// owner returns the DBX of a drone
+func (s *dbxStore) owner(droneId string) string {
+	return s.owners[droneId]
+}
+
+func (s *dbxStore) get(id string) (models.DroneBox, bool) {
+	box, found := s.boxes[id]
+	return box, found
+}
+
+// list returns the DBXs in their order of definition
+func (s *dbxStore) list() []models.DroneBox {
+	boxes := make([]models.DroneBox, 0, len(s.ids))
+	for _, id := range s.ids {
+		boxes = append(boxes, s.boxes[id])
+	}
+	return boxes
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"h3d-drone-emulator/models"
+)
+
+func TestNewDbxStore(t *testing.T) {
+	resources := []models.Resource{
+		{ID: "D1", Type: "DRONE", BaseLatitude: 1.3, BaseLongitude: 103.8},
+		{ID: "D2", Type: "DRONE", BaseLatitude: 1.4, BaseLongitude: 103.9},
+		{ID: "D3", Type: "DRONE"},
+		{ID: "V1", Type: "VEHICLE"},
+	}
+	tests := []struct {
+		name    string
+		boxes   []models.DroneBox
+		wantIds []string
+		// wantDrones are the drones of each DBX, in the order of wantIds
+		wantDrones [][]string
+	}{
+		{
+			name:       "default DBXs",
+			wantIds:    defaultDbxIds,
+			wantDrones: [][]string{{"D1"}, {"D2"}, {"D3"}},
+		},
+		{
+			name:       "every DBX skipped",
+			boxes:      []models.DroneBox{{Name: "no id"}, {Name: "no id either"}},
+			wantIds:    defaultDbxIds,
+			wantDrones: [][]string{{"D1"}, {"D2"}, {"D3"}},
+		},
+		{
+			name: "listed and spread drones",
+			boxes: []models.DroneBox{
+				{ID: "B1", DroneIds: []string{"D2", "V1", "unknown"}},
+				{ID: "B2", DroneIds: []string{"D2"}},
+				{ID: "B1"},
+			},
+			wantIds:    []string{"B1", "B2"},
+			wantDrones: [][]string{{"D2", "D1"}, {"D3"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newDbxStore(tt.boxes, resources, 1000)
+			if store.createdAt != 1000 {
+				t.Errorf("createdAt = %d, want 1000", store.createdAt)
+			}
+			if !reflect.DeepEqual(store.ids, tt.wantIds) {
+				t.Fatalf("ids = %v, want %v", store.ids, tt.wantIds)
+			}
+			for i, id := range tt.wantIds {
+				box, _ := store.get(id)
+				if !reflect.DeepEqual(box.DroneIds, tt.wantDrones[i]) {
+					t.Errorf("drones of %s = %v, want %v", id, box.DroneIds, tt.wantDrones[i])
+				}
+				for _, droneId := range tt.wantDrones[i] {
+					if owner := store.owner(droneId); owner != id {
+						t.Errorf("owner of %s = %s, want %s", droneId, owner, id)
+					}
+				}
+			}
+		})
+	}
+}
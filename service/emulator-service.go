@@ -6,29 +6,23 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"h3d-drone-emulator/api"
 	"h3d-drone-emulator/config"
 	"h3d-drone-emulator/models"
 	restrictedZone "h3d-drone-emulator/util"
 	"math"
-	"math/rand"
 	"net/http"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go/service/s3"
 	commonHttp "gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/http"
 	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
-	commonS3 "gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/s3"
 )
 
 var applicationConfig config.AppConfig
-var drones []models.DroneH3D
-
-var source restrictedZone.Point
-var destination restrictedZone.Point
+var fleet *FleetStore
+var simClock *SimClock
 
 // var missionStatuses []int = []int{0, 0, 0}
 
@@ -38,37 +32,43 @@ var destination restrictedZone.Point
 var textualStatuses []models.JSONData = []models.JSONData{{"Dbx_id": "123ABC", "Country": "Temasek", "network_type": "4G", "home_position": "1.334944,103.737051"}, {"Dbx_id": "1234567", "Country": "Singapore"}, {}}
 
 // var signalStrengths []string = []string{"Excellent", "Good", "Fair", "Bad"}
-var dbxIds []string = []string{"603f1c1dbf35eba727ee6c3a", "602c8187519cc032dd65e739", "604743f99ecead5820683080"}
 
 var httpClient *http.Client
 
-var resources []models.Resource
-var resourceStatusMap map[string]string
-var patrolStatus = "PATROL"
-var missionStatus = "MISSION"
-var returnToBaseStatus = "RETURN_TO_BASE"
-var missionChanMap map[string]chan string
 var patrolStopChan chan int
 
-var simuMap map[string][][]float64
-var simuMapMutex sync.RWMutex
-
-var locChan = make(chan models.Resource, 10)
+var assetSource AssetSource
 
-var s3Client *s3.S3
-
-func randomInt(min int, max int) int {
-	return rand.Intn(max-min) + min
-}
+var telemetrySinks []TelemetrySink
 
 func InitService() {
 	applicationConfig = config.Get()
 	httpClient = commonHttp.CreateHttpClient(nil)
-	s3, err := commonS3.CreateClient(config.Get().S3Config)
+	source, err := NewAssetSource(applicationConfig)
+	if err != nil {
+		panic("Could not create asset source: " + err.Error())
+	}
+	assetSource = source
+	sinks, err := NewTelemetrySinks(applicationConfig, httpClient)
+	if err != nil {
+		panic("Could not create telemetry sinks: " + err.Error())
+	}
+	for _, sink := range sinks {
+		telemetrySinks = append(telemetrySinks, newDeliveryQueue(sink, getDeliveryOptions()))
+	}
+	log.Info("Publishing telemetry to %v", telemetrySinks)
+	scenario, err := loadScenario()
 	if err != nil {
-		panic("Could not create S3 client: " + err.Error())
+		panic("Could not load scenario: " + err.Error())
 	}
-	s3Client = s3
+	initRandom(getSeed(scenario))
+	log.Info("Simulation seed: %d", simSeed)
+	sensorModel, err := NewSensorModel(applicationConfig)
+	if err != nil {
+		panic("Could not create sensor model: " + err.Error())
+	}
+	sensors = sensorModel
+	log.Info("Sensor model: %s", sensors)
 	// droneIds := strings.Split(*applicationConfig.DroneIds, ",")
 	// simulateRealH3D = *applicationConfig.SimulateRealH3D
 	// Publishes location of Drone every 5 seconds
@@ -78,41 +78,56 @@ func InitService() {
 	// 	go simulateMovement()
 	// }
 	// parse resources.json to the resources
-	if s3Client != nil {
-		log.Info("Getting resources.json from S3")
-		err := commonS3.ReadJsonFile(s3Client, "sdp-rms-external-simulator", "pilot_resources.json", &resources)
-		if err != nil {
-			log.Error("Could not get resources.json: %s", err.Error())
+	resources := scenario.Resources
+	if len(resources) > 0 {
+		log.Info("Using the %d resources of the scenario", len(resources))
+	} else {
+		log.Info("Getting %s from %s", *applicationConfig.ResourcesFile, assetSource)
+		if err := assetSource.ReadJSON(*applicationConfig.ResourcesFile, &resources); err != nil {
+			log.Error("Could not get %s: %s", *applicationConfig.ResourcesFile, err.Error())
 		}
 	}
 	log.Info("resources %#v", resources)
-	resourceStatusMap = make(map[string]string)
+	startTime := time.Now()
+	if scenario.StartTime != nil {
+		startTime = *scenario.StartTime
+	}
+	simClock = NewSimClock(startTime, *applicationConfig.SimSpeed)
+	fleet = NewFleetStore(simClock)
+	fleet.OnTransition(publishStateEvent)
+	zones = newZoneRegistry(*applicationConfig.NoFlyZoneEndPoint, *applicationConfig.ZonePageSize, time.Duration(*applicationConfig.ZoneRefreshInterval*float64(time.Second)))
+	localZones = newZoneStore(*applicationConfig.ZonesFile)
+	zones.setLocalZones(localZones.restrictedZones())
+	loadScenarioZones(scenario.Zones)
+	zones.start()
+
+	dbxs = newDbxStore(scenario.Dbxs, resources, simClock.Now().UnixNano()/int64(time.Millisecond))
 
 	// droneIds := make([]string, 0)
 	for i, res := range resources {
-		resourceStatusMap[res.ID] = patrolStatus
+		var drone *models.DroneH3D
 		if res.Type == "DRONE" {
-			drone := models.DroneH3D{
+			drone = &models.DroneH3D{
 				DroneId:          res.ID,
 				DroneName:        "H3d Drone " + strconv.Itoa(i+1),
 				CreatedBy:        "H3d",
-				DbxId:            dbxIds[i%3],
+				DbxId:            dbxs.owner(res.ID),
 				Company:          "H3d",
 				SerialNo:         "H3D00" + strconv.Itoa(i+1),
 				CurrLat:          res.BaseLatitude,
 				CurrLong:         res.BaseLongitude,
 				CurrAltitude:     0,
-				CurrHeading:      float64(randomInt(0, 360)),
-				DistanceFromHome: float64(randomInt(0, 51)),
-				GpsStatus:        randomInt(5, 7),
+				CurrHeading:      float64(randomResourceInt(res.ID, 0, 360)),
+				DistanceFromHome: float64(randomResourceInt(res.ID, 0, 51)),
+				GpsStatus:        randomResourceInt(res.ID, 5, 7),
 				HomeLat:          res.BaseLatitude,
 				HomeLong:         res.BaseLongitude,
-				BattLevel:        float64(randomInt(50, 101)),
+				BattLevel:        float64(randomResourceInt(res.ID, 50, 101)),
 				SignalStrength:   *applicationConfig.SignalStrength,
 				Temperature:      strconv.Itoa(*applicationConfig.Temperature),
 				TextualStatus:    textualStatuses[i%3],
 				ErrorCode:        0,
-				TimestampMs:      time.Now().UnixNano(),
+				TimestampMs:      simClock.Now().UnixNano(),
 				DroneVideo: models.DroneVideo{
 					Link1: `"flv: "http://localhost:8000/live/N2_cctv2.flv"`,
 					Link2: `m3u8: "http://localhost:8000/live/N2_cctv2.m3u8"`,
@@ -125,8 +140,8 @@ func InitService() {
 					Waypoints: [][]float64{},
 				},
 			}
-			drones = append(drones, drone)
 		}
+		fleet.Add(res, drone, models.StateIdle)
 	}
 
 	// Printing out Drone Information using regex
@@ -134,7 +149,6 @@ func InitService() {
 	// log.Info("%#v", drones)
 
 	// parse simulation files under files folder
-	simuMap = make(map[string][][]float64)
 	chunks := chunkResources(resources)
 	timeStart := time.Now()
 	var wg sync.WaitGroup
@@ -142,52 +156,67 @@ func InitService() {
 		for _, res := range chunk {
 			wg.Add(1)
 			go func(res models.Resource) {
-				if s3Client != nil {
-					log.Info("Processing %s", res.ID)
-					key := res.ID + ".csv"
-					simulation, err := commonS3.ReadBytes(s3Client, "sdp-rms-external-simulator", key)
-					if err != nil {
-						log.Error("Could not get %s: %s", key, err.Error())
-					}
-					waypoints := getWayPoints(simulation)
-					simuMapMutex.Lock()
-					simuMap[res.ID] = waypoints
-					simuMapMutex.Unlock()
-					wg.Done()
+				defer wg.Done()
+				log.Info("Processing %s", res.ID)
+				key := res.ID + ".csv"
+				simulation, err := assetSource.ReadBytes(key)
+				if err != nil {
+					log.Error("Could not get %s: %s", key, err.Error())
+				}
+				waypoints := getWayPoints(simulation)
+				fleet.SetPatrol(res.ID, waypoints)
+				if len(waypoints) > 0 {
+					fleet.Transition(res.ID, models.StatePatrol, "patrol loaded")
 				}
 			}(res)
 		}
 		wg.Wait()
 	}
 	log.Info("Time taken: %v", time.Since(timeStart))
-	/*
-		for k, v := range simuMap {
-			log.Info(k)
-			for i, point := range v {
-				log.Info("%d %f %f", i, point[0], point[1])
-			}
-		}
-	*/
-	go updateLocations()
 	patrolStopChan = make(chan int)
-	for k := range simuMap {
-		isVehicle := false
-		for _, res := range resources {
-			if res.ID == k {
-				isVehicle = res.IsVehicle
-				break
-			}
-		}
-		if len(simuMap[k]) > 0 {
-			// log.Info("Simu: %s %d", k, len(simuMap[k]))
-			go simulateResourcePatrol(k, isVehicle, patrolStopChan)
+	for _, res := range resources {
+		if len(fleet.Patrol(res.ID)) > 0 {
+			go simulateResourcePatrol(res.ID, res.IsVehicle, patrolStopChan)
 		}
 	}
 
-	missionChanMap = make(map[string]chan string)
-
 	// Simulates battery drop every 5 seconds
 	go simulateBatteryDrop(patrolStopChan)
+
+	// Runs the timeline of the scenario from the initial time of the clock
+	scenarioRun = newScenarioRunner(startTime, scenario.Events)
+	go scenarioRun.run()
+}
+
+// loadScenario reads the optional scenario file from the asset source. A missing file means the defaults,
+// a file that cannot be decoded or with an invalid event is an error.
+func loadScenario() (models.Scenario, error) {
+	var scenario models.Scenario
+	err := assetSource.ReadJSON(*applicationConfig.ScenarioFile, &scenario)
+	if errors.Is(err, ErrAssetNotFound) {
+		log.Info("No scenario %s in %s, using defaults", *applicationConfig.ScenarioFile, assetSource)
+		return models.Scenario{}, nil
+	}
+	if err != nil {
+		return models.Scenario{}, fmt.Errorf("%s in %s: %w", *applicationConfig.ScenarioFile, assetSource, err)
+	}
+	for i, event := range scenario.Events {
+		if err := validateScenarioEvent(event); err != nil {
+			return models.Scenario{}, fmt.Errorf("event %d of %s: %w", i, *applicationConfig.ScenarioFile, err)
+		}
+	}
+	return scenario, nil
+}
+
+// getSeed returns the seed of the run: the seed option, else the seed of the scenario, else a time based seed
+func getSeed(scenario models.Scenario) int64 {
+	if *applicationConfig.Seed != 0 {
+		return *applicationConfig.Seed
+	}
+	if scenario.Seed != nil {
+		return *scenario.Seed
+	}
+	return time.Now().UnixNano()
 }
 
 func getWayPoints(fStr []byte) [][]float64 {
@@ -217,14 +246,7 @@ func getWayPoints(fStr []byte) [][]float64 {
 func simulateResourcePatrol(resourceId string, isVehicle bool, stopChan chan int) {
 	i := 0
 	var stopped = false
-
-	var drone models.DroneH3D
-	for i, d := range drones {
-		if d.DroneId == resourceId {
-			drone = drones[i]
-			break
-		}
-	}
+	patrol := fleet.Patrol(resourceId)
 
 	for {
 		select {
@@ -238,152 +260,51 @@ func simulateResourcePatrol(resourceId string, isVehicle bool, stopChan chan int
 			log.Info("Produce for ID: %s stopped", resourceId)
 			break
 		}
-		i = i % len(simuMap[resourceId])
-		if resourceStatusMap[resourceId] == patrolStatus {
+		i = i % len(patrol)
+		if fleet.CurrentState(resourceId) == models.StatePatrol {
 			res := models.Resource{ID: resourceId,
 				Type:      "",
 				Name:      "",
-				Latitude:  simuMap[resourceId][i][0],
-				Longitude: simuMap[resourceId][i][1],
+				Latitude:  patrol[i][0],
+				Longitude: patrol[i][1],
 			}
 			log.Info("Produce for ID: %s  %d locations: %f %f", res.ID, i, res.Latitude, res.Longitude)
-			locChan <- res
-			location := strconv.FormatFloat(res.Latitude, 'E', -1, 64) + "," + strconv.FormatFloat(res.Longitude, 'E', -1, 64)
-			loc := models.ResourceLocation{
-				ResourceId:  resourceId,
-				Location:    location,
-				Altitude:    drone.CurrAltitude,
-				IsExternal:  true,
-				IsVehicle:   isVehicle,
-				TimestampMs: time.Now().UnixNano() / int64(time.Millisecond),
+			drone, _ := fleet.Drone(resourceId)
+			if next, reached := getPatrolStep(resourceId, isVehicle, restrictedZone.Point{Lat: res.Latitude, Lon: res.Longitude}); reached {
+				moveResource(resourceId, res.Latitude, res.Longitude, drone.CurrAltitude, isVehicle)
+				i++
+			} else {
+				moveResource(resourceId, next.Lat, next.Lon, drone.CurrAltitude, isVehicle)
 			}
-			sendLocation(loc)
-			i++
 		}
 		// Sleep for 20 seconds
-		time.Sleep(time.Second * 20)
+		simClock.Sleep(time.Second * 20)
 	}
 }
 
-func startResourceMission(mission models.MissionCommand, isVehicle bool) error {
-	currentLat := -1.0
-	currentLon := -1.0
-	for _, res := range resources {
-		if res.ID == *(mission.ResourceId) {
-			currentLat = res.Latitude
-			currentLon = res.Longitude
+// getPatrolStep returns where a patrolling resource goes on its way to the next patrol point.
+// It stops before the zones with a HOLD policy and goes around the zones with a REROUTE policy one corner at a time,
+// it returns true when the patrol point itself can be reached.
+func getPatrolStep(resourceId string, isVehicle bool, target restrictedZone.Point) (restrictedZone.Point, bool) {
+	if isVehicle {
+		return target, true
+	}
+	res, _ := fleet.Resource(resourceId)
+	position := restrictedZone.Point{Lat: res.Latitude, Lon: res.Longitude}
+	now := simClock.Now()
+	block, blocked := findGeofenceBlock(resourceId, position, target, now)
+	if !blocked {
+		geofence.release(resourceId)
+		return target, true
+	}
+	if block.policy == models.GeofencePolicyReroute {
+		if detour, found := planGeofenceDetour(resourceId, position, target, now); found && len(detour) > 2 {
+			geofence.block(resourceId, block, models.GeofenceReroute)
+			return detour[1], false
 		}
 	}
-	if currentLat < 0 || currentLon < 0 {
-		return errors.New("resource cannot be found")
-	}
-	waypoints := getStraightRoute([]float64{currentLat, currentLon}, mission.Waypoints[0])
-	i := 0
-	startMission := true
-	resourceStatusMap[*mission.ResourceId] = missionStatus
-	missionChan, found := missionChanMap[*mission.ResourceId]
-
-	if !found {
-		log.Error("%s mission channel not found", *mission.ResourceId)
-		return nil
-	}
-
-	for {
-
-		// need to check whether the stop mission is initiated
-		select {
-		case msg := <-missionChan:
-			log.Info("%s received stop mission message: %s", *(mission.ResourceId), msg)
-			startMission = false
-			// mission is stopped
-		default:
-		}
-		// Sleep for 5 seconds
-		time.Sleep(time.Second * 5)
-
-		if !startMission {
-			log.Info("%s mission completed", *(mission.ResourceId))
-			break
-		} else if i == (len(waypoints) - 1) {
-			log.Info("%s attending to mission %s", *(mission.ResourceId), *mission.MissionId)
-			continue
-		} else {
-			i++
-		}
-
-		res := models.Resource{ID: *mission.ResourceId,
-			Type:      "",
-			Name:      "",
-			Latitude:  waypoints[i][0],
-			Longitude: waypoints[i][1],
-		}
-		log.Info("Produce mission for ID: %s  %d locations: %f %f", res.ID, i, res.Latitude, res.Longitude)
-		locChan <- res
-		location := strconv.FormatFloat(res.Latitude, 'E', -1, 64) + "," + strconv.FormatFloat(res.Longitude, 'E', -1, 64)
-		loc := models.ResourceLocation{
-			ResourceId:  *mission.ResourceId,
-			Location:    location,
-			Altitude:    float64(*applicationConfig.Altitude),
-			IsExternal:  true,
-			IsVehicle:   isVehicle,
-			TimestampMs: time.Now().UnixNano() / int64(time.Millisecond),
-		}
-		sendLocation(loc)
-	}
-	droneId := *mission.ResourceId
-	go goBackToBase(droneId)
-
-	return nil
-}
-
-// base on source & dest with straight line and 2 mins movement to return the route
-func getStraightRoute(source []float64, dest []float64) [][]float64 {
-	// lat & lon
-	if len(source) != 2 || len(dest) != 2 {
-		return nil
-	}
-	log.Info("Route: source: %f %f dest: %f %f", source[0], source[1], dest[0], dest[1])
-	waypoints := make([][]float64, 13)
-	latDiff := (dest[0] - source[0]) / 12
-	lonDiff := (dest[1] - source[1]) / 12
-
-	waypoints[0] = make([]float64, 2)
-	waypoints[0][0] = source[0]
-	waypoints[0][1] = source[1]
-	for i := 1; i <= 12; i++ {
-		waypoints[i] = make([]float64, 2)
-		waypoints[i][0] = waypoints[i-1][0] + latDiff
-		waypoints[i][1] = waypoints[i-1][1] + lonDiff
-	}
-	return waypoints
-}
-
-func updateLocations() {
-	for {
-		loc := <-locChan
-		// log.Info("Consume with ID: %s locations: %f %f", loc.ID, loc.Latitude, loc.Longitude)
-
-		for i, res := range resources {
-			if res.ID == loc.ID {
-				resources[i].Latitude = loc.Latitude
-				resources[i].Longitude = loc.Longitude
-				break
-			}
-		}
-
-		for i, drone := range drones {
-			if drone.DroneId == loc.ID {
-				heading := getHeadingBetweenCoordinates([]float64{drones[i].CurrLat, drones[i].CurrLong}, []float64{loc.Latitude, loc.Longitude})
-				drones[i].CurrHeading = heading
-				drones[i].CurrLat = loc.Latitude
-				drones[i].CurrLong = loc.Longitude
-				break
-			}
-		}
-
-		time.Sleep(1 * time.Millisecond)
-
-	}
+	geofence.block(resourceId, block, models.GeofenceHold)
+	return block.stop, false
 }
 
 // func initiateDrone(droneId string) {
@@ -405,19 +326,9 @@ func updateLocations() {
 // 	}
 // }
 
-// Calculate distance in km using Pythagoras' theorem
+// Calculate great-circle distance in km
 func getDistanceBetweenCoordinates(start []float64, end []float64) float64 {
-	phi1 := start[0] * math.Pi / 180.0
-	phi2 := end[0] * math.Pi / 180.0
-	lambda1 := start[1] * math.Pi / 180.0
-	lambda2 := end[1] * math.Pi / 180.0
-
-	R := 6371000 // Radius of Earth in metres
-
-	x := (lambda2 - lambda1) * math.Cos((phi1+phi2)*math.Pi/2)
-	y := phi2 - phi1
-	distance := math.Sqrt(math.Pow(x, 2)+math.Pow(y, 2)) * float64(R) / 1000.0
-	return distance
+	return haversineDistance(restrictedZone.Point{Lat: start[0], Lon: start[1]}, restrictedZone.Point{Lat: end[0], Lon: end[1]})
 }
 
 func getHeadingBetweenCoordinates(start []float64, end []float64) float64 {
@@ -433,16 +344,29 @@ func getHeadingBetweenCoordinates(start []float64, end []float64) float64 {
 	return math.Round(heading)
 }
 
+// getDroneSpeed returns the current ground speed of a drone in the H3D format
+func getDroneSpeed(droneId string) string {
+	speed := fleet.Motion(droneId).GroundSpeed / restrictedZone.ConvertMphToMps(1)
+	return strconv.Itoa(int(math.Round(speed))) + " mph"
+}
+
 func sendDroneStatus(drone models.DroneH3D) error {
+	// Every value of the status is measured at the same time
+	now := simClock.Now()
+	drone.GpsStatus = getGpsStatus(drone.DroneId)
+	fleet.UpdateDrone(drone.DroneId, func(d *models.DroneH3D) {
+		d.GpsStatus = drone.GpsStatus
+	})
+	position := getReportedPosition(drone.DroneId, restrictedZone.Point{Lat: drone.CurrLat, Lon: drone.CurrLong}, now)
 	var h3dDrone = models.DroneH3dStatus{
 		BattLevel:        strconv.Itoa(int(math.Round(drone.BattLevel))),
-		DistanceFromHome: fmt.Sprintf("%.1f", getDistanceBetweenCoordinates([]float64{drone.HomeLat, drone.HomeLong}, []float64{drone.CurrLat, drone.CurrLong})),
-		DronesPosition:   fmt.Sprint(drone.CurrLat) + "," + fmt.Sprint(drone.CurrLong),
-		GpsStatus:        randomInt(5, 7),
+		DistanceFromHome: fmt.Sprintf("%.1f", drone.DistanceFromHome),
+		DronesPosition:   fmt.Sprint(position.Lat) + "," + fmt.Sprint(position.Lon),
+		GpsStatus:        drone.GpsStatus,
 		HomePosition:     fmt.Sprint(drone.HomeLat) + "," + fmt.Sprint(drone.HomeLong),
 		NetworkType:      drone.NetworkType,
-		SignalStrength:   drone.SignalStrength,
-		Temperature:      drone.Temperature,
+		SignalStrength:   getSignalStrength(drone.DroneId, drone.SignalStrength),
+		Temperature:      getReportedTemperature(drone.DroneId, drone.Temperature, now),
 		TextualStatus:    drone.TextualStatus,
 	}
 
@@ -451,54 +375,118 @@ func sendDroneStatus(drone models.DroneH3D) error {
 		h3dDrone.DroneSpeed = "0 mph"
 		h3dDrone.CurrHeading = 0
 	} else {
-		h3dDrone.Altitude = int(drone.CurrAltitude)
-		h3dDrone.DroneSpeed = strconv.Itoa(*applicationConfig.DroneSpeed) + " mph"
-		h3dDrone.CurrHeading = int(drone.CurrHeading)
+		h3dDrone.Altitude = int(getReportedAltitude(drone.DroneId, drone.CurrAltitude, now))
+		h3dDrone.DroneSpeed = getDroneSpeed(drone.DroneId)
+		h3dDrone.CurrHeading = getReportedHeading(drone.DroneId, int(drone.CurrHeading), now)
 	}
+	reports.setLocation(drone.DroneId, position, float64(h3dDrone.Altitude), now)
+	reports.setStatus(drone.DroneId, h3dDrone.CurrHeading, h3dDrone.Temperature, now)
 	var droneStatus = models.TransformDroneStatusFromH3dStatus(h3dDrone, drone.DroneId)
-	jsonDroneStatus, err := json.Marshal(droneStatus)
-	if err != nil {
-		log.Error(err.Error())
-		return err
-	}
+	droneStatus.TimestampMs = now.UnixNano() / int64(time.Millisecond)
+	droneStatus.GenTimestampMs = droneStatus.TimestampMs
 	log.Info("Produce for ID: %s statuses: %+v", drone.DroneId, droneStatus)
+	if links.hold(drone.DroneId, linkTelemetry{status: &droneStatus}) {
+		return nil
+	}
+	return deliverDroneStatus(droneStatus)
+}
 
-	postUrl := *applicationConfig.RestAPIAddress + *applicationConfig.ResourcesBasePath + "/" + drone.DroneId + "/status"
-	// log.Info("sendDroneStatus for %s", drone.DroneId)
+// deliverDroneStatus publishes a drone status to the stream clients and to every telemetry sink
+func deliverDroneStatus(droneStatus models.DroneStatus) error {
+	publishStatusEvent(droneStatus)
 
-	resp, err := httpClient.Post(postUrl, "application/json", bytes.NewBuffer(jsonDroneStatus))
-	if err != nil {
-		log.Error(err.Error())
-		return err
+	var sendErr error
+	for _, sink := range telemetrySinks {
+		if err := sink.SendDroneStatus(droneStatus); err != nil {
+			log.Error("Could not send status of %s to %s: %s", droneStatus.ResourceId, sink, err.Error())
+			sendErr = err
+		}
 	}
-	defer resp.Body.Close()
-	return nil
+	return sendErr
 }
 
-func sendLocation(loc models.ResourceLocation) error {
+// moveResource updates the position of a resource and publishes its new location, the altitude is in feet
+func moveResource(resourceId string, lat float64, lon float64, altitude float64, isVehicle bool) {
+	setResourcePosition(resourceId, lat, lon, altitude)
+	publishLocation(resourceId, isVehicle)
+}
 
-	postUrl := *applicationConfig.RestAPIAddress + *applicationConfig.ResourcesBasePath + "/" + loc.ResourceId + "/location"
-	// log.Info("sendLocations for %s", loc.ResourceId)
+// setResourcePosition updates the position of a resource and checks the no-fly zones on its way, the altitude is in feet.
+// Ground vehicles are not concerned by no-fly zones.
+func setResourcePosition(resourceId string, lat float64, lon float64, altitude float64) {
+	res, found := fleet.Resource(resourceId)
+	if !found {
+		return
+	}
+	fleet.UpdateLocation(resourceId, lat, lon)
+	fleet.UpdateDrone(resourceId, func(drone *models.DroneH3D) {
+		drone.CurrAltitude = altitude
+	})
+	if !res.IsVehicle {
+		geofence.update(resourceId, restrictedZone.Point{Lat: res.Latitude, Lon: res.Longitude}, restrictedZone.Point{Lat: lat, Lon: lon}, simClock.Now())
+	}
+}
 
-	jsonLoc, err := json.Marshal(loc)
-	if err != nil {
-		log.Error(err.Error())
-		return err
+// publishLocation sends the current location of a resource
+func publishLocation(resourceId string, isVehicle bool) {
+	res, found := fleet.Resource(resourceId)
+	if !found {
+		return
+	}
+	// No location without GPS fix
+	if faults.has(resourceId, models.FaultGpsLoss) {
+		return
 	}
+	altitude := 0.0
+	if drone, found := fleet.Drone(resourceId); found {
+		altitude = drone.CurrAltitude
+	}
+	// The location is measured at a single time, like the status
+	now := simClock.Now()
+	position := getReportedPosition(resourceId, restrictedZone.Point{Lat: res.Latitude, Lon: res.Longitude}, now)
+	altitude = getReportedAltitude(resourceId, altitude, now)
+	reports.setLocation(resourceId, position, altitude, now)
+	location := strconv.FormatFloat(position.Lat, 'E', -1, 64) + "," + strconv.FormatFloat(position.Lon, 'E', -1, 64)
+	loc := models.ResourceLocation{
+		ResourceId:  resourceId,
+		Location:    location,
+		Altitude:    altitude,
+		IsExternal:  true,
+		IsVehicle:   isVehicle,
+		TimestampMs: now.UnixNano() / int64(time.Millisecond),
+	}
+	sendLocation(loc)
+}
 
-	resp, err := httpClient.Post(postUrl, "application/json", bytes.NewBuffer(jsonLoc))
-	if err != nil {
-		log.Error(err.Error())
-		return err
+// sendLocation publishes a location, unless the link of the resource keeps it
+func sendLocation(loc models.ResourceLocation) error {
+	if links.hold(loc.ResourceId, linkTelemetry{location: &loc}) {
+		return nil
 	}
-	defer resp.Body.Close()
-	return nil
+	return deliverLocation(loc)
+}
+
+// deliverLocation publishes a location to the stream clients and to every telemetry sink
+func deliverLocation(loc models.ResourceLocation) error {
+	publishLocationEvent(loc)
+	var sendErr error
+	for _, sink := range telemetrySinks {
+		if err := sink.SendLocation(loc); err != nil {
+			log.Error("Could not send location of %s to %s: %s", loc.ResourceId, sink, err.Error())
+			sendErr = err
+		}
+	}
+	return sendErr
 }
 
 func simulateBatteryDrop(stopChan chan int) {
 	messageInterval := 5
-	depletionRate := float64(100) / (*applicationConfig.BatteryLife * 60)
+	last := simClock.Now()
 	for {
+		// A clock step may elapse more than one interval
+		now := simClock.Now()
+		elapsed := now.Sub(last).Seconds()
+		last = now
 		select {
 		case <-stopChan:
 			log.Info("simulateBatteryDrop received stopped")
@@ -507,56 +495,16 @@ func simulateBatteryDrop(stopChan chan int) {
 		}
 		// To keep drones actively managed in Drone Connector
 		// To change when doing autodiscovery
-		for i := 0; i < len(drones); i++ {
-			// Drone is charging at base
-			if drones[i].CurrLat == drones[i].HomeLat && drones[i].CurrLong == drones[i].HomeLong {
-				batteryLevel := drones[i].BattLevel + 10
-				if batteryLevel > 100 {
-					drones[i].BattLevel = 100
-				} else {
-					drones[i].BattLevel = batteryLevel
-				}
-			} else if drones[i].BattLevel <= 0 {
-				// Teleport drone back to base
-				res := models.Resource{
-					ID:        drones[i].DroneId,
-					Type:      "",
-					Name:      "",
-					Latitude:  drones[i].HomeLat,
-					Longitude: drones[i].HomeLong,
-				}
-				locChan <- res
-
-				isVehicle := false
-				for _, res := range resources {
-					if res.ID == drones[i].DroneId {
-						isVehicle = res.IsVehicle
-						break
-					}
-				}
-
-				location := strconv.FormatFloat(drones[i].HomeLat, 'E', -1, 64) + "," + strconv.FormatFloat(drones[i].HomeLong, 'E', -1, 64)
-				loc := models.ResourceLocation{
-					ResourceId:  drones[i].DroneId,
-					Location:    location,
-					Altitude:    0,
-					IsExternal:  true,
-					IsVehicle:   isVehicle,
-					TimestampMs: time.Now().UnixNano() / int64(time.Millisecond),
-				}
-				sendLocation(loc)
-			} else {
-				batteryLevel := float64(drones[i].BattLevel) - (float64(messageInterval) * depletionRate)
-				if batteryLevel < 0 {
-					drones[i].BattLevel = 0
-				} else {
-					drones[i].BattLevel = batteryLevel
-				}
+		for _, droneId := range fleet.ResourceIds() {
+			refreshGeofence(droneId)
+			drone, found := updateBattery(droneId, elapsed)
+			if !found {
+				continue
 			}
-			sendDroneStatus(drones[i])
+			sendDroneStatus(drone)
 		}
 		// Sleep for 5 seconds
-		time.Sleep(time.Second * time.Duration(messageInterval))
+		simClock.Sleep(time.Second * time.Duration(messageInterval))
 	}
 }
 
@@ -566,22 +514,8 @@ func GetDroneInfo(rc *models.RequestContext, droneId string) error {
 		log.Info("Requesting Drone's Info." + rc.EchoContext.Request().RequestURI)
 	}
 
-	i := checkIndex(droneId)
-	if i >= 0 {
-		var h3dDrone = models.DroneH3dStatus{
-			Altitude:         *applicationConfig.Altitude,
-			BattLevel:        strconv.Itoa(int(math.Round(drones[i].BattLevel))),
-			DistanceFromHome: fmt.Sprintf("%.1f", getDistanceBetweenCoordinates([]float64{drones[i].HomeLat, drones[i].HomeLong}, []float64{drones[i].CurrLat, drones[i].CurrLong})),
-			DroneSpeed:       strconv.Itoa(*applicationConfig.DroneSpeed) + " mph",
-			DronesPosition:   fmt.Sprint(drones[i].CurrLat) + "," + fmt.Sprint(drones[i].CurrLong),
-			GpsStatus:        randomInt(5, 7),
-			CurrHeading:      randomInt(0, 360),
-			HomePosition:     fmt.Sprint(drones[i].HomeLat) + "," + fmt.Sprint(drones[i].HomeLong),
-			NetworkType:      drones[i].NetworkType,
-			SignalStrength:   *applicationConfig.SignalStrength,
-			Temperature:      strconv.Itoa(*applicationConfig.Temperature),
-			TextualStatus:    drones[i].TextualStatus,
-		}
+	if drone, found := fleet.Drone(droneId); found {
+		h3dDrone := getDroneH3dStatus(drone)
 		json_data, err := json.Marshal(h3dDrone)
 		if err != nil {
 			log.Error(err.Error())
@@ -601,15 +535,42 @@ func GetDroneInfo(rc *models.RequestContext, droneId string) error {
 	return nil
 }
 
+// getDroneH3dStatus returns the status of a drone in the H3D format, with the values its sensors last reported
+// in its telemetry, or the true ones until it reports them
+func getDroneH3dStatus(drone models.DroneH3D) models.DroneH3dStatus {
+	report := reports.get(drone.DroneId)
+	position, altitude := restrictedZone.Point{Lat: drone.CurrLat, Lon: drone.CurrLong}, drone.CurrAltitude
+	if !report.locationAt.IsZero() {
+		position, altitude = report.position, report.altitude
+	}
+	heading, temperature := int(drone.CurrHeading), strconv.Itoa(*applicationConfig.Temperature)
+	if !report.statusAt.IsZero() {
+		heading, temperature = report.heading, report.temperature
+	}
+	return models.DroneH3dStatus{
+		Altitude:         int(altitude),
+		BattLevel:        strconv.Itoa(int(math.Round(drone.BattLevel))),
+		DistanceFromHome: fmt.Sprintf("%.1f", drone.DistanceFromHome),
+		DroneSpeed:       getDroneSpeed(drone.DroneId),
+		DronesPosition:   fmt.Sprint(position.Lat) + "," + fmt.Sprint(position.Lon),
+		GpsStatus:        drone.GpsStatus,
+		CurrHeading:      heading,
+		HomePosition:     fmt.Sprint(drone.HomeLat) + "," + fmt.Sprint(drone.HomeLong),
+		NetworkType:      drone.NetworkType,
+		SignalStrength:   getSignalStrength(drone.DroneId, *applicationConfig.SignalStrength),
+		Temperature:      temperature,
+		TextualStatus:    drone.TextualStatus,
+	}
+}
+
 func GetDroneVideo(rc *models.RequestContext, droneId string) error {
 	if rc != nil && rc.EchoContext != nil && rc.EchoContext.Request() != nil {
 		log.Info("Received REST GetDroneVideo from " + rc.EchoContext.Request().RemoteAddr)
 		log.Info("Requesting Drone's Video." + rc.EchoContext.Request().RequestURI)
 	}
 
-	index := checkIndex(droneId)
-	if index >= 0 {
-		json_data, err := json.Marshal(drones[index].DroneVideo)
+	if drone, found := fleet.Drone(droneId); found {
+		json_data, err := json.Marshal(drone.DroneVideo)
 		if err != nil {
 			log.Error(err.Error())
 			return err
@@ -617,7 +578,7 @@ func GetDroneVideo(rc *models.RequestContext, droneId string) error {
 
 		// Printing out Mission Details using regex
 		log.Info("Synchronous Response -> Drone Details:")
-		log.Info("%#v", drones[index].DroneVideo)
+		log.Info("%#v", drone.DroneVideo)
 
 		if rc != nil && rc.EchoContext != nil && rc.EchoContext.Response() != nil {
 			rc.EchoContext.Response().Header().Set("Content-Type", "application/json")
@@ -634,7 +595,7 @@ func GetAllDrones(rc *models.RequestContext) error {
 		log.Info("Requesting All Drone's Info." + rc.EchoContext.Request().RequestURI)
 	}
 
-	dronesH3d := models.TransformDroneH3dFromDrone(drones)
+	dronesH3d := models.TransformDroneH3dFromDrone(fleet.Drones())
 	json_data, err := json.Marshal(dronesH3d)
 	if err != nil {
 		log.Error(err.Error())
@@ -654,7 +615,7 @@ func GetAllDrones(rc *models.RequestContext) error {
 }
 
 func GetAllResources(rc *models.RequestContext) ([]models.Resource, error) {
-	return resources, nil
+	return fleet.Resources(), nil
 }
 
 func GetAllFlights(rc *models.RequestContext) error {
@@ -663,6 +624,7 @@ func GetAllFlights(rc *models.RequestContext) error {
 		log.Info("Requesting All Drone Flights." + rc.EchoContext.Request().RequestURI)
 	}
 
+	drones := fleet.Drones()
 	json_data, err := json.Marshal(drones)
 	if err != nil {
 		log.Error(err.Error())
@@ -681,28 +643,84 @@ func GetAllFlights(rc *models.RequestContext) error {
 	return nil
 }
 
-func GetAllDroneServers(rc *models.RequestContext) error {
-	if rc != nil && rc.EchoContext != nil && rc.EchoContext.Request() != nil {
-		log.Info("Received REST GetAllDroneServers from " + rc.EchoContext.Request().RemoteAddr)
-		log.Info("Requesting All Drone Servers." + rc.EchoContext.Request().RequestURI)
+// GetAllDroneServers returns the DBXs in the H3D format
+func GetAllDroneServers() ([]models.DbxH3DResponse, error) {
+	boxes := dbxs.list()
+	servers := make([]models.DbxH3DResponse, 0, len(boxes))
+	for _, box := range boxes {
+		server := models.DbxH3DResponse{
+			DbxId:       models.Dbx{Oid: box.ID},
+			Name:        box.Name,
+			SerialNo:    box.SerialNo,
+			CreateBy:    "H3d",
+			Company:     "H3d",
+			Status:      getDbxStatus(box),
+			Position:    fmt.Sprint(box.Latitude) + "," + fmt.Sprint(box.Longitude),
+			Drones:      make([]models.DroneH3DId, 0, len(box.DroneIds)),
+			TimestampMs: models.DroneH3dCreateAt{CreateAtDate: dbxs.createdAt},
+		}
+		for _, droneId := range box.DroneIds {
+			server.Drones = append(server.Drones, models.DroneH3DId{DroneOid: droneId})
+		}
+		servers = append(servers, server)
 	}
+	return servers, nil
+}
 
-	json_data, err := json.Marshal(drones)
-	if err != nil {
-		log.Error(err.Error())
-		return err
+// GetDroneServer reads the status of the drones of a DBX in the H3D format
+func GetDroneServer(dbxId string) (models.DbxH3dRead, error) {
+	box, found := dbxs.get(dbxId)
+	if !found {
+		return models.DbxH3dRead{}, ErrDbxNotFound
+	}
+	read := models.DbxH3dRead{
+		DbxId:  models.Dbx{Oid: box.ID},
+		Status: getDbxStatus(box),
+		Drones: make([]models.DbxH3dDroneStatus, 0, len(box.DroneIds)),
+	}
+	for _, droneId := range box.DroneIds {
+		if drone, found := fleet.Drone(droneId); found {
+			read.Drones = append(read.Drones, models.DbxH3dDroneStatus{
+				DroneId:        models.DroneH3DId{DroneOid: droneId},
+				DroneH3dStatus: getDroneH3dStatus(drone),
+			})
+		}
 	}
+	return read, nil
+}
 
-	// Printing out Mission Details using regex
-	log.Info("Synchronous Response -> All Drone Details:")
-	log.Info("%#v", drones)
+// GetDroneServerVideo returns the video feeds of the drones of a DBX in the H3D format
+func GetDroneServerVideo(dbxId string) (models.DbxH3dVideo, error) {
+	box, found := dbxs.get(dbxId)
+	if !found {
+		return models.DbxH3dVideo{}, ErrDbxNotFound
+	}
+	video := models.DbxH3dVideo{
+		DbxId:  models.Dbx{Oid: box.ID},
+		Drones: make([]models.DbxH3dDroneVideo, 0, len(box.DroneIds)),
+	}
+	for _, droneId := range box.DroneIds {
+		if drone, found := fleet.Drone(droneId); found {
+			video.Drones = append(video.Drones, models.DbxH3dDroneVideo{
+				DroneId:    models.DroneH3DId{DroneOid: droneId},
+				DroneVideo: drone.DroneVideo,
+			})
+		}
+	}
+	return video, nil
+}
 
-	if rc != nil && rc.EchoContext != nil && rc.EchoContext.Response() != nil {
-		rc.EchoContext.Response().Header().Set("Content-Type", "application/json")
-		rc.EchoContext.Response().WriteHeader(http.StatusOK)
-		rc.EchoContext.Response().Writer.Write(json_data)
+// getDbxStatus returns whether a DBX is online, which it is unless the links of all its drones are lost
+func getDbxStatus(box models.DroneBox) string {
+	if len(box.DroneIds) == 0 {
+		return models.DbxOnline
 	}
-	return nil
+	for _, droneId := range box.DroneIds {
+		if !isLinkLost(droneId) {
+			return models.DbxOnline
+		}
+	}
+	return models.DbxOffline
 }
 
 /*func simulateStartStopMission(c echo.Context) error {
@@ -710,75 +728,113 @@ func GetAllDroneServers(rc *models.RequestContext) error {
 	w.WriteHeader(http.StatusOK)
 }*/
 
-func checkIndex(droneId string) int {
-	index := -1
-	for i := 0; i < len(drones); i++ {
-		if droneId == drones[i].DroneId {
-			index = i
-		}
+func StartResourceMission(mission models.MissionCommand) error {
+	if err := validateMission(mission); err != nil {
+		return err
+	}
+	if !fleet.Exists(*mission.ResourceId) {
+		return ErrResourceNotFound
 	}
-	return index
+	return sendCommand(*mission.ResourceId, func() error {
+		return runMissionCommand(mission)
+	})
 }
 
-func StartResourceMission(mission models.MissionCommand) error {
-	var missionChan chan string
-	var exists = false
-	var isVehicle = false
-	for _, res := range resources {
-		if res.ID == *mission.ResourceId {
-			exists = true
-			isVehicle = res.IsVehicle
-		}
-	}
+// runMissionCommand starts a mission once the command reached the resource
+func runMissionCommand(mission models.MissionCommand) error {
+	res, exists := fleet.Resource(*mission.ResourceId)
 	if !exists {
-		return errors.New("resource not found")
+		return ErrResourceNotFound
 	}
 
-	// Create resource channel if not exists
-	if theChan, found := missionChanMap[*mission.ResourceId]; found {
-		missionChan = theChan
-	} else {
-		missionChan = make(chan string)
-		missionChanMap[*mission.ResourceId] = missionChan
-	}
-
-	// Check current status of resource
-	if resourceStatusMap[*mission.ResourceId] == returnToBaseStatus {
-		go func(messageChan chan string) {
-			messageChan <- "START"
-			fmt.Println("sent message", "START")
-			startResourceMission(mission, isVehicle)
-		}(missionChan)
-	} else if resourceStatusMap[*mission.ResourceId] == missionStatus {
-		return errors.New(*mission.ResourceId + " is not available")
-	} else {
-		go startResourceMission(mission, isVehicle)
+	missionId := ""
+	if mission.MissionId != nil {
+		missionId = *mission.MissionId
 	}
+	waypoints := mission.GetMissionWaypoints()
+	progress := models.MissionProgress{
+		CurrentWaypointIndex: 0,
+		WaypointCount:        len(waypoints),
+		OnComplete:           getMissionOnComplete(mission),
+	}
+
+	// Drones wait for the clearance of the no-fly zones on their path
+	clearanceStatus := models.ClearanceGranted
+	if !res.IsVehicle {
+		if crossed := getMissionClearanceZones(res, waypoints); len(crossed) > 0 {
+			if missionId == "" {
+				missionId = newObjectId()
+				mission.MissionId = &missionId
+			}
+			clearance := clearances.request(missionId, res.ID, crossed, simClock.Now())
+			clearanceStatus = clearance.Status
+		}
+	}
+	switch clearanceStatus {
+	case models.ClearanceDenied:
+		return fmt.Errorf("%w for mission %s", ErrClearanceDenied, missionId)
+	case models.ClearancePending:
+		if err := fleet.AwaitClearance(*mission.ResourceId, missionId, progress); err != nil {
+			return err
+		}
+	default:
+		if err := fleet.Dispatch(*mission.ResourceId, missionId, progress); err != nil {
+			return err
+		}
+	}
+	fleet.UpdateDrone(*mission.ResourceId, func(drone *models.DroneH3D) {
+		drone.Mission.NewMission.MissionName = missionId
+		if mission.MissionName != nil {
+			drone.Mission.NewMission.MissionName = *mission.MissionName
+		}
+		drone.Mission.Waypoints = make([][]float64, 0, len(waypoints))
+		for _, waypoint := range waypoints {
+			drone.Mission.Waypoints = append(drone.Mission.Waypoints, []float64{waypoint.Latitude, waypoint.Longitude})
+		}
+	})
+
+	// Stops the return to base, if any
+	stop, _ := fleet.StartActivity(*mission.ResourceId)
+	go startResourceMission(mission, res.IsVehicle, stop)
 
 	return nil
 }
 
 func StopResourceMission(mission models.MissionCommand) error {
-	var exists = false
-	for _, res := range resources {
-		if res.ID == *mission.ResourceId {
-			exists = true
-		}
+	if mission.ResourceId == nil {
+		return fmt.Errorf("%w: resourceId is required", ErrInvalidMission)
 	}
-	if !exists {
-		return errors.New("resource not found")
+	resourceId := *mission.ResourceId
+	if !fleet.Exists(resourceId) {
+		return ErrResourceNotFound
 	}
-	if theChan, found := missionChanMap[*mission.ResourceId]; found {
-		go func(messageChan chan string) {
-			messageChan <- "STOP"
-			fmt.Println("sent message", "STOP")
-		}(theChan)
+	return sendCommand(resourceId, func() error {
+		if err := fleet.Transition(resourceId, models.StateReturning, "mission stopped"); err != nil {
+			return err
+		}
+		stop, _ := fleet.StartActivity(resourceId)
+		go goBackToBase(resourceId, stop)
+		return nil
+	})
+}
+
+// GetResourceState returns the lifecycle state of a resource
+func GetResourceState(resourceId string) (models.ResourceStateInfo, error) {
+	state, found := fleet.State(resourceId)
+	if !found {
+		return state, ErrResourceNotFound
 	}
-	return nil
+	return state, nil
 }
 
 func Dispose() {
-
+	scenarioRun.close()
+	zones.close()
+	for _, sink := range telemetrySinks {
+		if err := sink.Close(); err != nil {
+			log.Error("Could not close %s: %s", sink, err.Error())
+		}
+	}
 }
 
 // func StartMission(rc *models.RequestContext, missionDetails models.Mission) error {
@@ -835,12 +891,15 @@ func GetMissionDetails(rc *models.RequestContext, droneId string) error {
 		log.Info("Received REST GetMissionDetails from " + rc.EchoContext.Request().RemoteAddr)
 		log.Info("Get Mission Details." + rc.EchoContext.Request().RequestURI)
 	}
-	missionDetails := models.Mission{
-		//MissionId:   drone1.Mission.MissionId,
-		NewMission: models.NewMission{
-			MissionName: drones[0].Mission.NewMission.MissionName,
-		},
-		Waypoints: drones[0].Mission.Waypoints,
+	var missionDetails models.Mission
+	if drone, found := fleet.Drone(droneId); found {
+		missionDetails = models.Mission{
+			//MissionId:   drone1.Mission.MissionId,
+			NewMission: models.NewMission{
+				MissionName: drone.Mission.NewMission.MissionName,
+			},
+			Waypoints: drone.Mission.Waypoints,
+		}
 	}
 	json_data, err := json.Marshal(missionDetails)
 
@@ -1106,183 +1165,17 @@ func updateDroneSquadOperationalStatus(droneId string, status string) {
 	httpClient.Do(newRequest)
 }
 
-func goBackToBase(resourceId string) error {
-	resourceStatusMap[resourceId] = returnToBaseStatus
-
-	currentLat := -1.0
-	currentLon := -1.0
-	baseLat := -1.0
-	baseLon := -1.0
-
-	for _, res := range resources {
-		if res.ID == resourceId {
-			currentLat = res.Latitude
-			currentLon = res.Longitude
-			baseLat = res.BaseLatitude
-			baseLon = res.BaseLongitude
-		}
-	}
-	if currentLat < 0 || currentLon < 0 {
-		return errors.New("resource cannot be found")
-	}
-	waypoints := getStraightRoute([]float64{currentLat, currentLon}, []float64{baseLat, baseLon})
-	i := 0
-	missionChan := missionChanMap[resourceId]
-
-	for {
-		// Listen for new start mission message
-		select {
-		case msg := <-missionChan:
-			log.Info("%s received start mission message: %s, terminating return to base", resourceId, msg)
-			resourceStatusMap[resourceId] = patrolStatus
-			return nil
-		default:
-		}
-
-		// Sleep for 5 seconds
-		time.Sleep(time.Second * 5)
-
-		// reach the dest, stop update the locations
-		if i == (len(waypoints) - 1) {
-			log.Info("%s has reached base", resourceId)
-			break
-		} else {
-			i++
-		}
-
-		res := models.Resource{ID: resourceId,
-			Type:      "",
-			Name:      "",
-			Latitude:  waypoints[i][0],
-			Longitude: waypoints[i][1],
-		}
-		log.Info("Back to base ID: %s  %d locations: %f %f", res.ID, i, res.Latitude, res.Longitude)
-		locChan <- res
-		location := strconv.FormatFloat(res.Latitude, 'E', -1, 64) + "," + strconv.FormatFloat(res.Longitude, 'E', -1, 64)
-		loc := models.ResourceLocation{
-			ResourceId:  resourceId,
-			Location:    location,
-			Altitude:    float64(*applicationConfig.Altitude),
-			IsExternal:  true,
-			IsVehicle:   false,
-			TimestampMs: time.Now().UnixNano() / int64(time.Millisecond),
-		}
-		sendLocation(loc)
-	}
-
-	resourceStatusMap[resourceId] = patrolStatus
-
-	for i, drone := range drones {
-		if drone.DroneId == resourceId {
-			drones[i].CurrAltitude = 0
-		}
-	}
-
-	return nil
-}
-
-func getRestrictedZone() []restrictedZone.RestrictedZone {
-	client := api.NewClient()
-
-	requestBody := models.RequestBody{
-		CeInstance: models.CeInstance{
-			TemplateID: models.TemplateID{
-				Equals: []string{"no_fly_zone"},
-			}},
-		Offset:    0,
-		Limit:     1000,
-		Order:     "creation_date:asc",
-		WithTotal: true,
-	}
-
-	response, err := client.Post(*config.Get().NoFlyZoneEndPoint, requestBody)
-	if err != nil {
-		log.Error("Failed to get response: %v", err)
-	}
-
-	var result models.ApiResponse
-	if err := json.Unmarshal(response, &result); err != nil {
-		log.Error("Failed to unmarshal response: %v", err)
-	}
-	var restrictedZones []restrictedZone.RestrictedZone
-	for _, instance := range result.CeInstances {
-		var polygon []restrictedZone.Point
-		id := instance.ID
-		if outer, ok := instance.Geometry.Coordinates.([]interface{}); ok {
-			if middle, ok := outer[0].([]interface{}); ok {
-				if inner, ok := middle[0].([]interface{}); ok {
-					for _, coordinate := range inner {
-						if firstCoordinate, ok := coordinate.([]interface{}); ok {
-							// Extract latitude and longitude from the first coordinate
-							if len(firstCoordinate) >= 2 {
-								longitude := firstCoordinate[0].(float64)
-								latitude := firstCoordinate[1].(float64)
-								polygon = append(polygon, restrictedZone.Point{Lat: latitude, Lon: longitude})
-							}
-						}
-					}
-				}
-			}
-		}
-
-		restrictedZone := restrictedZone.RestrictedZone{
-			ID:        id,
-			Polygon:   polygon,
-			StartTime: instance.Data.ActivationStart.TimestampMs,
-			EndTime:   instance.Data.ActivationEnd.TimestampMs,
-		}
-		restrictedZones = append(restrictedZones, restrictedZone)
-	}
-	return restrictedZones
-}
-
-func GetSourceDestinationPoints(coordinates string) (restrictedZone.Point, restrictedZone.Point, error) {
-	parts := strings.Split(coordinates, ":")
-
-	// Split the first part to get the source lat/lon
-	sourceCoords := strings.Split(parts[0], ",")
-	sourceLat, _ := strconv.ParseFloat(sourceCoords[0], 64)
-	sourceLon, _ := strconv.ParseFloat(sourceCoords[1], 64)
-	source = restrictedZone.Point{Lat: sourceLat, Lon: sourceLon}
-
-	// Split the second part to get the destination lat/lon
-	destinationCoords := strings.Split(parts[1], ",")
-	destinationLat, _ := strconv.ParseFloat(destinationCoords[0], 64)
-	destinationLon, _ := strconv.ParseFloat(destinationCoords[1], 64)
-	destination = restrictedZone.Point{Lat: destinationLat, Lon: destinationLon}
-
-	fmt.Println(source.Lat)
-	fmt.Println(destination.Lat)
-	return source, destination, nil
-}
-
-func GetPath(rc *models.RequestContext, query string) (float64, []models.ClearanceZone, error) {
-	restrictedZones := getRestrictedZone()
-	source, destination, nil := GetSourceDestinationPoints(query)
-
-	// Check if the path intersects any active no-fly zones
-	currentTime := time.Now()
-	droneSpeed := *applicationConfig.DroneSpeed
-
-	isPathInRestrictedZone, crossedZones := restrictedZone.IsPathInRestrictedZone(source, destination, restrictedZones, currentTime, float64(droneSpeed))
-	distance := haversineDistance(source, destination)
-	distanceInMeters := distance * 1000
-	if isPathInRestrictedZone {
-		fmt.Println("The path intersects an active no-fly zone!")
-		return distanceInMeters, crossedZones, nil
-	} else {
-		fmt.Println("The path does not intersect any active no-fly zone.")
-		return distanceInMeters, crossedZones, nil
-	}
-
-}
-
 func GetRemainingOperationTimeAtLocation(resourceId string, travelTimeInSeconds float64) float64 {
-	i := checkIndex(resourceId)
-	depletionRate := float64(100) / (*applicationConfig.BatteryLife * 60)
-	batteryLevel := float64(drones[i].BattLevel) - (travelTimeInSeconds * depletionRate)
-	remainingOperationTimeAtLocation := (1 / depletionRate) * batteryLevel
-	return remainingOperationTimeAtLocation
+	drone, found := fleet.Drone(resourceId)
+	if !found {
+		return 0
+	}
+	// The travel is flown at cruise speed, the wind is not accounted for as it depends on the legs,
+	// then the drone hovers at the location until it has to land in place
+	model := getBatteryModel()
+	batteryLevel := drone.BattLevel - (travelTimeInSeconds * model.cruiseDrain * model.payloadFactor) - *applicationConfig.BatteryLandLevel
+	remainingOperationTimeAtLocation := batteryLevel / model.drain(kinematicState{})
+	return math.Max(0, remainingOperationTimeAtLocation)
 }
 
 func haversineDistance(p1, p2 restrictedZone.Point) float64 {
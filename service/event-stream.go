@@ -0,0 +1,143 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"h3d-drone-emulator/models"
+)
+
+// streamBufferSize is the number of events buffered per stream client, events are dropped for clients too slow to keep up
+const streamBufferSize = 256
+
+// ErrInvalidStreamFilter is returned when a stream filter has invalid parameters
+var ErrInvalidStreamFilter = errors.New("invalid stream filter")
+
+// streamSubscriber is a stream client with its filter
+type streamSubscriber struct {
+	filter models.StreamFilter
+	events chan models.StreamEvent
+}
+
+// matches tells whether the event passes the filter of the subscriber
+func (s *streamSubscriber) matches(event models.StreamEvent) bool {
+	return matchesAny(s.filter.ResourceIds, event.ResourceId) &&
+		matchesAny(s.filter.ResourceTypes, event.ResourceType) &&
+		matchesAny(s.filter.EventTypes, event.Type)
+}
+
+func matchesAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventStream fans the live events out to the stream clients
+type eventStream struct {
+	mutex       sync.RWMutex
+	subscribers map[*streamSubscriber]struct{}
+}
+
+var liveStream = &eventStream{subscribers: make(map[*streamSubscriber]struct{})}
+
+func (s *eventStream) subscribe(filter models.StreamFilter) *streamSubscriber {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	subscriber := &streamSubscriber{filter: filter, events: make(chan models.StreamEvent, streamBufferSize)}
+	s.subscribers[subscriber] = struct{}{}
+	return subscriber
+}
+
+func (s *eventStream) unsubscribe(subscriber *streamSubscriber) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, found := s.subscribers[subscriber]; found {
+		delete(s.subscribers, subscriber)
+		close(subscriber.events)
+	}
+}
+
+// publish sends the event to every matching subscriber without ever blocking
+func (s *eventStream) publish(event models.StreamEvent) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for subscriber := range s.subscribers {
+		if !subscriber.matches(event) {
+			continue
+		}
+		select {
+		case subscriber.events <- event:
+		default:
+		}
+	}
+}
+
+// publishLocationEvent pushes a location update to the stream clients
+func publishLocationEvent(location models.ResourceLocation) {
+	res, _ := fleet.Resource(location.ResourceId)
+	liveStream.publish(models.StreamEvent{
+		Type:         models.StreamEventLocation,
+		ResourceId:   location.ResourceId,
+		ResourceType: res.Type,
+		TimestampMs:  location.TimestampMs,
+		Location:     &location,
+	})
+}
+
+// publishStatusEvent pushes a drone status update to the stream clients
+func publishStatusEvent(status models.DroneStatus) {
+	res, _ := fleet.Resource(status.ResourceId)
+	liveStream.publish(models.StreamEvent{
+		Type:         models.StreamEventStatus,
+		ResourceId:   status.ResourceId,
+		ResourceType: res.Type,
+		TimestampMs:  status.TimestampMs,
+		Status:       &status,
+	})
+}
+
+// publishStateEvent pushes a state change to the stream clients
+func publishStateEvent(resource models.Resource, state models.ResourceStateInfo, transition models.StateTransition) {
+	liveStream.publish(models.StreamEvent{
+		Type:         models.StreamEventState,
+		ResourceId:   resource.ID,
+		ResourceType: resource.Type,
+		TimestampMs:  transition.Timestamp.UnixNano() / int64(time.Millisecond),
+		Transition:   &transition,
+		MissionId:    state.MissionId,
+	})
+}
+
+// publishGeofenceEvent pushes a geofence event to the stream clients
+func publishGeofenceEvent(resourceId string, event models.GeofenceEvent) {
+	res, _ := fleet.Resource(resourceId)
+	liveStream.publish(models.StreamEvent{
+		Type:         models.StreamEventGeofence,
+		ResourceId:   resourceId,
+		ResourceType: res.Type,
+		TimestampMs:  simClock.Now().UnixNano() / int64(time.Millisecond),
+		Geofence:     &event,
+	})
+}
+
+// SubscribeStream registers a stream client, the returned function must be called once the client is gone
+func SubscribeStream(filter models.StreamFilter) (<-chan models.StreamEvent, func(), error) {
+	for _, eventType := range filter.EventTypes {
+		switch strings.ToLower(eventType) {
+		case models.StreamEventLocation, models.StreamEventStatus, models.StreamEventState, models.StreamEventGeofence:
+		default:
+			return nil, nil, fmt.Errorf("%w: type must be one of location, status, state or geofence", ErrInvalidStreamFilter)
+		}
+	}
+	subscriber := liveStream.subscribe(filter)
+	return subscriber.events, func() { liveStream.unsubscribe(subscriber) }, nil
+}
@@ -0,0 +1,360 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
+
+	"h3d-drone-emulator/models"
+	restrictedZone "h3d-drone-emulator/util"
+)
+
+// ErrInvalidFault is returned when a fault command has an unknown type or a negative delay or duration
+var ErrInvalidFault = errors.New("invalid fault")
+
+// faultErrorCodes are the error codes reported for each fault, the most severe faults have the highest codes
+var faultErrorCodes = map[string]int{
+	models.FaultGpsDegraded:   11,
+	models.FaultGpsLoss:       12,
+	models.FaultCompassError:  21,
+	models.FaultStuckPosition: 31,
+	models.FaultLinkLoss:      41,
+	models.FaultLowBattery:    51,
+	models.FaultOverheating:   61,
+	models.FaultMotorFailure:  71,
+}
+
+// gpsDegradedError is the maximum error in meters of the positions reported with a degraded GPS
+const gpsDegradedError = 30.0
+
+// overheatingDelta is the rise in degrees Celsius of the temperature reported by an overheating drone
+const overheatingDelta = 45
+
+// activeFault is a fault of a resource with the position reported when it was injected,
+// and the random values of its effect drawn for the last telemetry tick
+type activeFault struct {
+	models.Fault
+	position restrictedZone.Point
+	drawn    []float64
+	drawnAt  time.Time
+}
+
+// faultStore holds the active and scheduled faults of each resource
+type faultStore struct {
+	mutex     sync.Mutex
+	faults    map[string]map[string]activeFault
+	scheduled map[string][]models.ScheduledFault
+}
+
+var faults = &faultStore{faults: make(map[string]map[string]activeFault), scheduled: make(map[string][]models.ScheduledFault)}
+
+func (s *faultStore) inject(resourceId string, fault activeFault) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.faults[resourceId] == nil {
+		s.faults[resourceId] = make(map[string]activeFault)
+	}
+	s.faults[resourceId][fault.Type] = fault
+}
+
+// clear removes a fault, only once its end is reached if expired is set, and tells whether it was active
+func (s *faultStore) clear(resourceId string, faultType string, now time.Time, expired bool) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	fault, found := s.faults[resourceId][faultType]
+	if !found || expired && (fault.Until == nil || now.Before(*fault.Until)) {
+		return false
+	}
+	delete(s.faults[resourceId], faultType)
+	return true
+}
+
+func (s *faultStore) get(resourceId string, faultType string) (activeFault, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	fault, found := s.faults[resourceId][faultType]
+	return fault, found
+}
+
+func (s *faultStore) has(resourceId string, faultType string) bool {
+	_, found := s.get(resourceId, faultType)
+	return found
+}
+
+// draw returns count random values in [0, 1) for the effect of an active fault at a time, drawn from the fault stream
+// of the resource once per time so that the location and the status of a telemetry tick report the same error
+func (s *faultStore) draw(resourceId string, faultType string, now time.Time, count int) ([]float64, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	fault, found := s.faults[resourceId][faultType]
+	if !found {
+		return nil, false
+	}
+	if len(fault.drawn) != count || now.After(fault.drawnAt) {
+		random := resourceStream(resourceId, streamFaults)
+		fault.drawn = make([]float64, count)
+		for i := range fault.drawn {
+			fault.drawn[i] = random.Float64()
+		}
+		fault.drawnAt = now
+		s.faults[resourceId][faultType] = fault
+	}
+	return fault.drawn, true
+}
+
+func (s *faultStore) schedule(resourceId string, scheduled models.ScheduledFault) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.scheduled[resourceId] = append(s.scheduled[resourceId], scheduled)
+}
+
+func (s *faultStore) unschedule(resourceId string, scheduled models.ScheduledFault) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	list := s.scheduled[resourceId]
+	for i := range list {
+		if list[i] == scheduled {
+			s.scheduled[resourceId] = append(list[:i:i], list[i+1:]...)
+			return
+		}
+	}
+}
+
+// list returns the faults of a resource sorted by type and the scheduled commands sorted by time
+func (s *faultStore) list(resourceId string) models.ResourceFaults {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	resourceFaults := models.ResourceFaults{
+		ResourceId: resourceId,
+		Faults:     make([]models.Fault, 0, len(s.faults[resourceId])),
+		Scheduled:  make([]models.ScheduledFault, len(s.scheduled[resourceId])),
+	}
+	for _, fault := range s.faults[resourceId] {
+		resourceFaults.Faults = append(resourceFaults.Faults, fault.Fault)
+		if fault.ErrorCode > resourceFaults.ErrorCode {
+			resourceFaults.ErrorCode = fault.ErrorCode
+		}
+	}
+	sort.Slice(resourceFaults.Faults, func(i, j int) bool {
+		return resourceFaults.Faults[i].Type < resourceFaults.Faults[j].Type
+	})
+	copy(resourceFaults.Scheduled, s.scheduled[resourceId])
+	sort.SliceStable(resourceFaults.Scheduled, func(i, j int) bool {
+		return resourceFaults.Scheduled[i].At.Before(resourceFaults.Scheduled[j].At)
+	})
+	return resourceFaults
+}
+
+// scheduleFault applies a fault command once the delay of simulation time is elapsed
+func scheduleFault(resourceId string, command models.FaultCommand, delay time.Duration) {
+	scheduled := models.ScheduledFault{Type: command.Type, Clear: command.Clear, At: simClock.Now().Add(delay)}
+	faults.schedule(resourceId, scheduled)
+	go func() {
+		<-simClock.After(delay)
+		faults.unschedule(resourceId, scheduled)
+		applyFault(resourceId, command, false)
+	}()
+}
+
+// applyFault injects or clears a fault now, with its effects on the behavior of the resource.
+// An expired clear only clears a fault whose duration is elapsed, so that it does not clear a fault injected again since.
+func applyFault(resourceId string, command models.FaultCommand, expired bool) {
+	now := simClock.Now()
+	if command.Clear {
+		if faults.clear(resourceId, command.Type, now, expired) {
+			log.Info("Cleared fault %s of %s", command.Type, resourceId)
+			onFaultCleared(resourceId, command.Type)
+			updateFaultStatus(resourceId)
+		}
+		return
+	}
+
+	fault := activeFault{Fault: models.Fault{Type: command.Type, ErrorCode: faultErrorCodes[command.Type], Since: now}}
+	if res, found := fleet.Resource(resourceId); found {
+		fault.position = reports.lastPosition(resourceId, restrictedZone.Point{Lat: res.Latitude, Lon: res.Longitude})
+	}
+	if command.DurationSeconds > 0 {
+		duration := time.Duration(command.DurationSeconds * float64(time.Second))
+		until := now.Add(duration)
+		fault.Until = &until
+		expiry := models.FaultCommand{Type: command.Type, Clear: true}
+		scheduled := models.ScheduledFault{Type: command.Type, Clear: true, At: until}
+		faults.schedule(resourceId, scheduled)
+		go func() {
+			<-simClock.After(duration)
+			faults.unschedule(resourceId, scheduled)
+			applyFault(resourceId, expiry, true)
+		}()
+	}
+	faults.inject(resourceId, fault)
+	log.Info("Injected fault %s in %s", command.Type, resourceId)
+	onFaultInjected(resourceId, command.Type)
+	updateFaultStatus(resourceId)
+}
+
+// onFaultInjected applies the behavior of the resource on a new fault
+func onFaultInjected(resourceId string, faultType string) {
+	switch faultType {
+	case models.FaultLinkLoss:
+		links.setState(resourceId, models.LinkLost)
+	case models.FaultOverheating:
+		forceReturnToBase(resourceId, "overheating")
+	case models.FaultMotorFailure:
+		forceLanding(resourceId, "motor failure")
+	case models.FaultLowBattery:
+		fleet.UpdateDrone(resourceId, func(drone *models.DroneH3D) {
+			if drone.BattLevel > *applicationConfig.BatteryRtlLevel {
+				drone.BattLevel = *applicationConfig.BatteryRtlLevel
+			}
+		})
+	}
+}
+
+// onFaultCleared applies the behavior of the resource once a fault is cleared: the link comes back,
+// a resource on the ground after a motor failure becomes available again and resumes its patrol, if any
+func onFaultCleared(resourceId string, faultType string) {
+	if faultType == models.FaultLinkLoss {
+		links.setState(resourceId, models.LinkConnected)
+		return
+	}
+	if faultType != models.FaultMotorFailure || fleet.CurrentState(resourceId) != models.StateFault {
+		return
+	}
+	if drone, found := fleet.Drone(resourceId); found && drone.CurrAltitude > 0 {
+		return
+	}
+	if err := fleet.Transition(resourceId, models.StateIdle, "motor repaired"); err != nil {
+		log.Error(err.Error())
+		return
+	}
+	if len(fleet.Patrol(resourceId)) > 0 {
+		fleet.Transition(resourceId, models.StatePatrol, "resuming patrol")
+	}
+}
+
+// updateFaultStatus reports the active faults and the error code of a drone
+func updateFaultStatus(resourceId string) {
+	resourceFaults := faults.list(resourceId)
+	types := make([]string, 0, len(resourceFaults.Faults))
+	for _, fault := range resourceFaults.Faults {
+		types = append(types, fault.Type)
+	}
+	fleet.UpdateDrone(resourceId, func(drone *models.DroneH3D) {
+		drone.ErrorCode = resourceFaults.ErrorCode
+		textualStatus := make(models.JSONData, len(drone.TextualStatus)+2)
+		for k, v := range drone.TextualStatus {
+			textualStatus[k] = v
+		}
+		if len(types) > 0 {
+			textualStatus[models.TextualStatusFaults] = types
+			textualStatus[models.TextualStatusErrorCode] = resourceFaults.ErrorCode
+		} else {
+			delete(textualStatus, models.TextualStatusFaults)
+			delete(textualStatus, models.TextualStatusErrorCode)
+		}
+		drone.TextualStatus = textualStatus
+	})
+}
+
+// getSignalStrength returns the signal strength reported by a drone, poor on a degraded link and none on a lost link
+func getSignalStrength(droneId string, signalStrength string) string {
+	switch links.state(droneId) {
+	case models.LinkDegraded:
+		return "Poor"
+	case models.LinkLost:
+		return "No Signal"
+	}
+	return signalStrength
+}
+
+// getGpsStatus returns the GPS status reported by a drone, 0 when its GPS is lost.
+// It is drawn from the fault stream of the drone, on the status ticks only.
+func getGpsStatus(droneId string) int {
+	if faults.has(droneId, models.FaultGpsLoss) {
+		return 0
+	}
+	random := resourceStream(droneId, streamFaults)
+	if faults.has(droneId, models.FaultGpsDegraded) {
+		return random.Intn(2) + 2
+	}
+	return random.Intn(2) + 5
+}
+
+// getReportedPosition returns the position reported by a resource at a time: frozen when its GPS is lost or its position stuck,
+// else measured by the sensor model, off by up to gpsDegradedError more meters with a degraded GPS
+func getReportedPosition(resourceId string, position restrictedZone.Point, now time.Time) restrictedZone.Point {
+	for _, faultType := range []string{models.FaultGpsLoss, models.FaultStuckPosition} {
+		if fault, found := faults.get(resourceId, faultType); found {
+			return fault.position
+		}
+	}
+	position = sensors.Position(resourceId, position, now)
+	if offset, found := faults.draw(resourceId, models.FaultGpsDegraded, now, 2); found {
+		return restrictedZone.DestinationPoint(position, offset[0]*360, offset[1]*gpsDegradedError)
+	}
+	return position
+}
+
+// getReportedAltitude returns the altitude in feet reported by a resource at a time, measured by the sensor model
+func getReportedAltitude(resourceId string, altitude float64, now time.Time) float64 {
+	return sensors.Altitude(resourceId, altitude, now)
+}
+
+// getReportedHeading returns the heading reported by a drone at a time, erratic with a compass error
+func getReportedHeading(droneId string, heading int, now time.Time) int {
+	if erratic, found := faults.draw(droneId, models.FaultCompassError, now, 1); found {
+		return int(erratic[0] * 360)
+	}
+	return sensors.Heading(droneId, heading, now)
+}
+
+// getReportedTemperature returns the temperature reported by a drone at a time, following its flight time with the sensor model
+// and raised when it overheats
+func getReportedTemperature(droneId string, temperature string, now time.Time) string {
+	degrees, err := strconv.Atoi(temperature)
+	if err != nil {
+		degrees = *applicationConfig.Temperature
+	}
+	// Flying like for the battery, patrols being flown at cruise
+	airborne := fleet.CurrentState(droneId) == models.StatePatrol
+	if drone, found := fleet.Drone(droneId); found && (drone.CurrAltitude > 0 || fleet.Motion(droneId).GroundSpeed > 0) {
+		airborne = true
+	}
+	degrees = sensors.Temperature(droneId, degrees, airborne, now)
+	if faults.has(droneId, models.FaultOverheating) {
+		degrees += overheatingDelta
+	}
+	return strconv.Itoa(degrees)
+}
+
+// ApplyFaultCommand injects or clears a fault of a resource, immediately or after the delay of the command
+func ApplyFaultCommand(resourceId string, command models.FaultCommand) (models.ResourceFaults, error) {
+	if !fleet.Exists(resourceId) {
+		return models.ResourceFaults{}, ErrResourceNotFound
+	}
+	if _, found := faultErrorCodes[command.Type]; !found {
+		return models.ResourceFaults{}, fmt.Errorf("%w: type must be one of GPS_LOSS, GPS_DEGRADED, LINK_LOSS, MOTOR_FAILURE, OVERHEATING, COMPASS_ERROR, LOW_BATTERY or STUCK_POSITION", ErrInvalidFault)
+	}
+	if command.DelaySeconds < 0 || command.DurationSeconds < 0 {
+		return models.ResourceFaults{}, fmt.Errorf("%w: delaySeconds and durationSeconds cannot be negative", ErrInvalidFault)
+	}
+	if command.DelaySeconds > 0 {
+		scheduleFault(resourceId, command, time.Duration(command.DelaySeconds*float64(time.Second)))
+	} else {
+		applyFault(resourceId, command, false)
+	}
+	return faults.list(resourceId), nil
+}
+
+// GetResourceFaults returns the active and scheduled faults of a resource
+func GetResourceFaults(resourceId string) (models.ResourceFaults, error) {
+	if !fleet.Exists(resourceId) {
+		return models.ResourceFaults{}, ErrResourceNotFound
+	}
+	return faults.list(resourceId), nil
+}
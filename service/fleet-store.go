@@ -0,0 +1,327 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"h3d-drone-emulator/models"
+)
+
+// FleetStore owns the state of every emulated resource.
+// All mutations are serialized, readers get snapshots.
+type FleetStore struct {
+	clock        Clock
+	mutex        sync.RWMutex
+	ids          []string
+	entries      map[string]*fleetEntry
+	onTransition func(resource models.Resource, state models.ResourceStateInfo, transition models.StateTransition)
+}
+
+// fleetEntry is the state of one resource, the drone is nil for resources which are not drones
+type fleetEntry struct {
+	resource models.Resource
+	drone    *models.DroneH3D
+	state    models.ResourceStateInfo
+	motion   kinematicState
+	patrol   [][]float64
+	stop     chan struct{}
+}
+
+// NewFleetStore creates an empty fleet, the clock timestamps the state transitions
+func NewFleetStore(clock Clock) *FleetStore {
+	return &FleetStore{clock: clock, entries: make(map[string]*fleetEntry)}
+}
+
+// Add registers a resource and its drone, if any. Adding an existing ID replaces it.
+func (f *FleetStore) Add(res models.Resource, drone *models.DroneH3D, state models.ResourceState) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, found := f.entries[res.ID]; !found {
+		f.ids = append(f.ids, res.ID)
+	}
+	entry := &fleetEntry{resource: res, state: models.ResourceStateInfo{
+		ResourceId:  res.ID,
+		State:       state,
+		Since:       f.clock.Now(),
+		Transitions: []models.StateTransition{},
+	}}
+	if drone != nil {
+		d := copyDrone(*drone)
+		entry.drone = &d
+	}
+	f.entries[res.ID] = entry
+}
+
+// OnTransition registers a function called on every state change.
+// It is called with the fleet locked and must not use the fleet.
+func (f *FleetStore) OnTransition(onTransition func(resource models.Resource, state models.ResourceStateInfo, transition models.StateTransition)) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.onTransition = onTransition
+}
+
+// Exists returns true if the resource is managed by the fleet
+func (f *FleetStore) Exists(id string) bool {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	_, found := f.entries[id]
+	return found
+}
+
+// Resource returns a snapshot of a resource
+func (f *FleetStore) Resource(id string) (models.Resource, bool) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	entry, found := f.entries[id]
+	if !found {
+		return models.Resource{}, false
+	}
+	return entry.resource, true
+}
+
+// Resources returns a snapshot of all resources, in insertion order
+func (f *FleetStore) Resources() []models.Resource {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	resources := make([]models.Resource, 0, len(f.ids))
+	for _, id := range f.ids {
+		resources = append(resources, f.entries[id].resource)
+	}
+	return resources
+}
+
+// ResourceIds returns the IDs of all resources, in insertion order
+func (f *FleetStore) ResourceIds() []string {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	ids := make([]string, len(f.ids))
+	copy(ids, f.ids)
+	return ids
+}
+
+// Drone returns a snapshot of a drone
+func (f *FleetStore) Drone(id string) (models.DroneH3D, bool) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	entry, found := f.entries[id]
+	if !found || entry.drone == nil {
+		return models.DroneH3D{}, false
+	}
+	return copyDrone(*entry.drone), true
+}
+
+// Drones returns a snapshot of all drones, in insertion order
+func (f *FleetStore) Drones() []models.DroneH3D {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	drones := make([]models.DroneH3D, 0, len(f.ids))
+	for _, id := range f.ids {
+		if drone := f.entries[id].drone; drone != nil {
+			drones = append(drones, copyDrone(*drone))
+		}
+	}
+	return drones
+}
+
+// UpdateLocation moves a resource, and its drone if any, to a new position
+func (f *FleetStore) UpdateLocation(id string, lat float64, lon float64) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	entry, found := f.entries[id]
+	if !found {
+		return false
+	}
+	entry.resource.Latitude = lat
+	entry.resource.Longitude = lon
+	if drone := entry.drone; drone != nil {
+		if drone.CurrLat != lat || drone.CurrLong != lon {
+			drone.CurrHeading = getHeadingBetweenCoordinates([]float64{drone.CurrLat, drone.CurrLong}, []float64{lat, lon})
+		}
+		drone.CurrLat = lat
+		drone.CurrLong = lon
+		drone.DistanceFromHome = getDistanceBetweenCoordinates([]float64{drone.HomeLat, drone.HomeLong}, []float64{lat, lon}) * 1000
+	}
+	return true
+}
+
+// Motion returns the speeds of a resource, the position is held by the resource itself
+func (f *FleetStore) Motion(id string) kinematicState {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	if entry, found := f.entries[id]; found {
+		return entry.motion
+	}
+	return kinematicState{}
+}
+
+// SetMotion changes the speeds of a resource
+func (f *FleetStore) SetMotion(id string, motion kinematicState) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if entry, found := f.entries[id]; found {
+		entry.motion = motion
+	}
+}
+
+// UpdateDrone applies a mutation to a drone while holding the fleet lock and returns the resulting snapshot
+func (f *FleetStore) UpdateDrone(id string, update func(drone *models.DroneH3D)) (models.DroneH3D, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	entry, found := f.entries[id]
+	if !found || entry.drone == nil {
+		return models.DroneH3D{}, false
+	}
+	update(entry.drone)
+	return copyDrone(*entry.drone), true
+}
+
+// State returns a snapshot of the lifecycle state of a resource
+func (f *FleetStore) State(id string) (models.ResourceStateInfo, bool) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	entry, found := f.entries[id]
+	if !found {
+		return models.ResourceStateInfo{}, false
+	}
+	state := entry.state
+	state.Transitions = make([]models.StateTransition, len(entry.state.Transitions))
+	copy(state.Transitions, entry.state.Transitions)
+	if entry.state.Progress != nil {
+		progress := *entry.state.Progress
+		state.Progress = &progress
+	}
+	return state, true
+}
+
+// CurrentState returns the lifecycle state of a resource, or an empty state if the resource is unknown
+func (f *FleetStore) CurrentState(id string) models.ResourceState {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	if entry, found := f.entries[id]; found {
+		return entry.state.State
+	}
+	return ""
+}
+
+// Transition moves a resource to a new state if the transition is allowed from its current state
+func (f *FleetStore) Transition(id string, to models.ResourceState, reason string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	entry, found := f.entries[id]
+	if !found {
+		return ErrResourceNotFound
+	}
+	if err := entry.transition(to, reason, f.clock.Now()); err != nil {
+		return err
+	}
+	f.notifyTransition(entry)
+	return nil
+}
+
+// Dispatch moves a resource to the dispatching state and assigns it a mission
+func (f *FleetStore) Dispatch(id string, missionId string, progress models.MissionProgress) error {
+	return f.assignMission(id, models.StateDispatching, missionId, progress)
+}
+
+// AwaitClearance assigns a mission to a resource which waits for its clearance before being dispatched
+func (f *FleetStore) AwaitClearance(id string, missionId string, progress models.MissionProgress) error {
+	return f.assignMission(id, models.StatePendingClearance, missionId, progress)
+}
+
+func (f *FleetStore) assignMission(id string, to models.ResourceState, missionId string, progress models.MissionProgress) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	entry, found := f.entries[id]
+	if !found {
+		return ErrResourceNotFound
+	}
+	if err := entry.transition(to, "mission "+missionId, f.clock.Now()); err != nil {
+		return err
+	}
+	entry.state.MissionId = missionId
+	entry.state.Progress = &progress
+	f.notifyTransition(entry)
+	return nil
+}
+
+// notifyTransition calls the transition function with the latest transition of the entry, the caller must hold the lock
+func (f *FleetStore) notifyTransition(entry *fleetEntry) {
+	if f.onTransition == nil || len(entry.state.Transitions) == 0 {
+		return
+	}
+	f.onTransition(entry.resource, entry.state, entry.state.Transitions[len(entry.state.Transitions)-1])
+}
+
+// UpdateProgress changes the mission progress of a resource on mission
+func (f *FleetStore) UpdateProgress(id string, update func(progress *models.MissionProgress)) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if entry, found := f.entries[id]; found && entry.state.Progress != nil {
+		update(entry.state.Progress)
+	}
+}
+
+func (e *fleetEntry) transition(to models.ResourceState, reason string, now time.Time) error {
+	from := e.state.State
+	if !canTransition(from, to) {
+		return &TransitionError{ResourceId: e.resource.ID, From: from, To: to}
+	}
+	e.state.State = to
+	e.state.Since = now
+	if !isOnMission(to) && to != models.StateReturning {
+		e.state.MissionId = ""
+		e.state.Progress = nil
+	}
+	e.state.Transitions = append(e.state.Transitions, models.StateTransition{From: from, To: to, Reason: reason, Timestamp: now})
+	if len(e.state.Transitions) > maxStateTransitions {
+		e.state.Transitions = e.state.Transitions[len(e.state.Transitions)-maxStateTransitions:]
+	}
+	return nil
+}
+
+// StartActivity stops the running activity of a resource (mission, return to base), if any,
+// and returns the stop channel of the new activity
+func (f *FleetStore) StartActivity(id string) (<-chan struct{}, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	entry, found := f.entries[id]
+	if !found {
+		return nil, false
+	}
+	if entry.stop != nil {
+		close(entry.stop)
+	}
+	entry.stop = make(chan struct{})
+	return entry.stop, true
+}
+
+// SetPatrol sets the patrol waypoints of a resource
+func (f *FleetStore) SetPatrol(id string, waypoints [][]float64) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if entry, found := f.entries[id]; found {
+		entry.patrol = waypoints
+	}
+}
+
+// Patrol returns the patrol waypoints of a resource. The waypoints are never mutated once set.
+func (f *FleetStore) Patrol(id string) [][]float64 {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	if entry, found := f.entries[id]; found {
+		return entry.patrol
+	}
+	return nil
+}
+
+// copyDrone copies a drone so that the snapshot does not share the textual status
+func copyDrone(drone models.DroneH3D) models.DroneH3D {
+	if drone.TextualStatus != nil {
+		textualStatus := make(models.JSONData, len(drone.TextualStatus))
+		for k, v := range drone.TextualStatus {
+			textualStatus[k] = v
+		}
+		drone.TextualStatus = textualStatus
+	}
+	return drone
+}
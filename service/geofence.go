@@ -0,0 +1,214 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
+
+	"h3d-drone-emulator/models"
+	restrictedZone "h3d-drone-emulator/util"
+)
+
+// geofenceMargin is the distance in meters kept from the boundary of a zone by a resource stopped before it
+const geofenceMargin = 1.0
+
+// geofenceBlock is an active zone with a HOLD or REROUTE policy about to be entered by a resource
+type geofenceBlock struct {
+	zone   restrictedZone.RestrictedZone
+	policy string
+	// stop is the last point before the zone on the way of the resource
+	stop restrictedZone.Point
+}
+
+// geofenceMonitor tracks the zones each resource is in, with their policy, and the zone each resource is stopped by
+type geofenceMonitor struct {
+	mutex   sync.Mutex
+	inside  map[string]map[string]string
+	blocked map[string]string
+}
+
+var geofence = &geofenceMonitor{inside: make(map[string]map[string]string), blocked: make(map[string]string)}
+
+// isGeofencePolicy tells whether the policy of a zone is valid, empty for the default policy
+func isGeofencePolicy(policy string) bool {
+	switch strings.ToUpper(policy) {
+	case "", models.GeofencePolicyHold, models.GeofencePolicyReroute, models.GeofencePolicyContinue:
+		return true
+	}
+	return false
+}
+
+// geofencePolicy returns the policy of the zone, the configured policy if the zone has none
+func geofencePolicy(zone restrictedZone.RestrictedZone) string {
+	switch policy := strings.ToUpper(zone.Policy); policy {
+	case models.GeofencePolicyHold, models.GeofencePolicyReroute, models.GeofencePolicyContinue:
+		return policy
+	}
+	return strings.ToUpper(*applicationConfig.GeofencePolicy)
+}
+
+// getGuardedZones returns the zones active at the time which the resource must not enter,
+// the zones its mission is cleared for are not guarded
+func getGuardedZones(resourceId string, now time.Time) []restrictedZone.RestrictedZone {
+	state, _ := fleet.State(resourceId)
+	guarded := make([]restrictedZone.RestrictedZone, 0)
+	for _, zone := range getActiveZones(zones.snapshot().zones, now, now) {
+		if geofencePolicy(zone) != models.GeofencePolicyContinue && !clearances.isGranted(state.MissionId, zone.ID) {
+			guarded = append(guarded, zone)
+		}
+	}
+	return guarded
+}
+
+// findGeofenceBlock returns the first guarded zone entered on the way from one point to the other.
+// The zones the resource is already in do not block it, so that it can leave them.
+func findGeofenceBlock(resourceId string, from, to restrictedZone.Point, now time.Time) (geofenceBlock, bool) {
+	block, found, nearest := geofenceBlock{}, false, math.Inf(1)
+	for _, zone := range getGuardedZones(resourceId, now) {
+		for _, crossing := range restrictedZone.FindZoneCrossings(from, to, zone) {
+			if crossing.EntryDistance == 0 {
+				continue
+			}
+			if crossing.EntryDistance < nearest {
+				nearest = crossing.EntryDistance
+				stop := restrictedZone.DestinationPoint(from, restrictedZone.InitialBearing(from, to), math.Max(0, crossing.EntryDistance-geofenceMargin))
+				block, found = geofenceBlock{zone: zone, policy: geofencePolicy(zone), stop: stop}, true
+			}
+			break
+		}
+	}
+	return block, found
+}
+
+// planGeofenceDetour returns the way around the guarded zones from one point to the other
+func planGeofenceDetour(resourceId string, from, to restrictedZone.Point, now time.Time) ([]restrictedZone.Point, bool) {
+	return restrictedZone.PlanAvoidanceRoute(from, to, getGuardedZones(resourceId, now), *applicationConfig.AvoidanceBuffer)
+}
+
+// update finds the zones entered and left by a resource moving from one point to the other,
+// reports them on the live stream and flags the drone while it is in a zone
+func (g *geofenceMonitor) update(resourceId string, from, to restrictedZone.Point, now time.Time) {
+	g.mutex.Lock()
+	inside := g.inside[resourceId]
+	next := make(map[string]string)
+	events := make([]models.GeofenceEvent, 0)
+	length := restrictedZone.DistanceMeters(from, to)
+	event := func(zone restrictedZone.RestrictedZone, action string, at restrictedZone.Point) {
+		events = append(events, models.GeofenceEvent{ZoneId: zone.ID, Action: action, Policy: geofencePolicy(zone), Latitude: at.Lat, Longitude: at.Lon})
+	}
+	for _, zone := range getActiveZones(zones.snapshot().zones, now, now) {
+		_, isInside := inside[zone.ID]
+		crossings := restrictedZone.FindZoneCrossings(from, to, zone)
+		if isInside && (len(crossings) == 0 || crossings[0].EntryDistance > 0) {
+			event(zone, models.GeofenceExit, from)
+			isInside = false
+		}
+		for _, crossing := range crossings {
+			if !isInside {
+				event(zone, models.GeofenceEnter, crossing.Entry)
+			}
+			isInside = true
+			if crossing.ExitDistance < length {
+				event(zone, models.GeofenceExit, crossing.Exit)
+				isInside = false
+			}
+		}
+		if isInside {
+			next[zone.ID] = geofencePolicy(zone)
+		}
+	}
+	// Zones removed or deactivated are left where the resource is
+	for zoneId, policy := range inside {
+		if _, found := next[zoneId]; !found && !hasGeofenceEvent(events, zoneId) {
+			events = append(events, models.GeofenceEvent{ZoneId: zoneId, Action: models.GeofenceExit, Policy: policy, Latitude: to.Lat, Longitude: to.Lon})
+		}
+	}
+	g.inside[resourceId] = next
+	g.mutex.Unlock()
+
+	for _, e := range events {
+		log.Info("%s geofence %s zone %s (%s)", resourceId, e.Action, e.ZoneId, e.Policy)
+		publishGeofenceEvent(resourceId, e)
+	}
+	if len(events) > 0 {
+		setGeofenceViolation(resourceId, next)
+	}
+}
+
+// refreshGeofence checks the zones a standing resource is in, as zones are created, changed or deactivated around it
+func refreshGeofence(resourceId string) {
+	res, found := fleet.Resource(resourceId)
+	if !found || res.IsVehicle {
+		return
+	}
+	position := restrictedZone.Point{Lat: res.Latitude, Lon: res.Longitude}
+	geofence.update(resourceId, position, position, simClock.Now())
+}
+
+func hasGeofenceEvent(events []models.GeofenceEvent, zoneId string) bool {
+	for _, event := range events {
+		if event.ZoneId == zoneId {
+			return true
+		}
+	}
+	return false
+}
+
+// block reports a resource stopped or rerouted at the boundary of a zone, once until it is released
+func (g *geofenceMonitor) block(resourceId string, block geofenceBlock, action string) {
+	g.mutex.Lock()
+	key := block.zone.ID + "/" + action
+	if g.blocked[resourceId] == key {
+		g.mutex.Unlock()
+		return
+	}
+	g.blocked[resourceId] = key
+	g.mutex.Unlock()
+
+	log.Info("%s geofence %s before zone %s", resourceId, action, block.zone.ID)
+	publishGeofenceEvent(resourceId, models.GeofenceEvent{
+		ZoneId:    block.zone.ID,
+		Action:    action,
+		Policy:    block.policy,
+		Latitude:  block.stop.Lat,
+		Longitude: block.stop.Lon,
+	})
+}
+
+// release clears the zone a resource was stopped by
+func (g *geofenceMonitor) release(resourceId string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	delete(g.blocked, resourceId)
+}
+
+// setGeofenceViolation flags the textual status of a drone with the zones it is in without clearance,
+// the flag is removed once it is out
+func setGeofenceViolation(resourceId string, inside map[string]string) {
+	state, _ := fleet.State(resourceId)
+	zoneIds := make([]string, 0, len(inside))
+	for zoneId := range inside {
+		if !clearances.isGranted(state.MissionId, zoneId) {
+			zoneIds = append(zoneIds, zoneId)
+		}
+	}
+	sort.Strings(zoneIds)
+	fleet.UpdateDrone(resourceId, func(drone *models.DroneH3D) {
+		textualStatus := make(models.JSONData, len(drone.TextualStatus)+2)
+		for k, v := range drone.TextualStatus {
+			textualStatus[k] = v
+		}
+		if len(zoneIds) > 0 {
+			textualStatus[models.TextualStatusGeofenceViolation] = true
+			textualStatus[models.TextualStatusGeofenceZones] = zoneIds
+		} else {
+			delete(textualStatus, models.TextualStatusGeofenceViolation)
+			delete(textualStatus, models.TextualStatusGeofenceZones)
+		}
+		drone.TextualStatus = textualStatus
+	})
+}
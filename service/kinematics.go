@@ -0,0 +1,125 @@
+package service
+
+import (
+	"math"
+
+	"h3d-drone-emulator/models"
+	restrictedZone "h3d-drone-emulator/util"
+)
+
+// arrivalTolerance is the distance in meters under which a target is considered reached
+const arrivalTolerance = 0.5
+
+// kinematicState is the motion state of a resource, distances in meters and speeds in meters per second
+type kinematicState struct {
+	Position      restrictedZone.Point
+	Altitude      float64
+	GroundSpeed   float64
+	VerticalSpeed float64
+	Heading       float64
+}
+
+// motionProfile is the performance envelope of a resource
+type motionProfile struct {
+	CruiseSpeed  float64
+	Acceleration float64
+	ClimbRate    float64
+}
+
+// getMotionProfile returns the configured motion profile of a drone or of a ground vehicle,
+// a positive cruise speed in meters per second overrides the configured one
+func getMotionProfile(isVehicle bool, cruiseSpeed float64) motionProfile {
+	profile := motionProfile{
+		CruiseSpeed:  restrictedZone.ConvertMphToMps(float64(*applicationConfig.DroneSpeed)),
+		Acceleration: *applicationConfig.Acceleration,
+		ClimbRate:    *applicationConfig.ClimbRate,
+	}
+	if isVehicle {
+		profile.CruiseSpeed = restrictedZone.ConvertMphToMps(float64(*applicationConfig.VehicleSpeed))
+		profile.ClimbRate = 0
+	}
+	if cruiseSpeed > 0 {
+		profile.CruiseSpeed = cruiseSpeed
+	}
+	return profile
+}
+
+// advance moves the state toward the target during dt seconds along the great circle,
+// accelerating up to cruise speed and slowing down to stop on the target.
+// It returns true once the target position and altitude are reached.
+func (k *kinematicState) advance(target restrictedZone.Point, targetAltitude float64, profile motionProfile, dt float64) bool {
+	remaining := restrictedZone.DistanceMeters(k.Position, target)
+
+	// Horizontal motion
+	if remaining <= arrivalTolerance {
+		k.Position = target
+		k.GroundSpeed = 0
+	} else {
+		k.Heading = restrictedZone.InitialBearing(k.Position, target)
+		// Fastest speed which still allows to stop on the target
+		maxSpeed := profile.CruiseSpeed
+		if profile.Acceleration > 0 {
+			maxSpeed = math.Min(maxSpeed, math.Sqrt(2*profile.Acceleration*remaining))
+		}
+		speed := maxSpeed
+		if profile.Acceleration > 0 {
+			if k.GroundSpeed < maxSpeed {
+				speed = math.Min(maxSpeed, k.GroundSpeed+profile.Acceleration*dt)
+			} else {
+				speed = math.Max(maxSpeed, k.GroundSpeed-profile.Acceleration*dt)
+			}
+		}
+		// Always make some progress to avoid crawling forever on the last centimeters,
+		// the floor does not depend on dt as the last step of a clock step may be very short
+		speed = math.Max(speed, arrivalTolerance)
+		step := (k.GroundSpeed + speed) / 2 * dt
+		if step >= remaining {
+			k.Position = target
+			k.GroundSpeed = 0
+		} else {
+			k.Position = restrictedZone.DestinationPoint(k.Position, k.Heading, step)
+			k.GroundSpeed = speed
+		}
+	}
+
+	// Vertical motion
+	climb := targetAltitude - k.Altitude
+	if profile.ClimbRate <= 0 || math.Abs(climb) <= profile.ClimbRate*dt {
+		k.Altitude = targetAltitude
+		k.VerticalSpeed = 0
+	} else {
+		k.VerticalSpeed = math.Copysign(profile.ClimbRate, climb)
+		k.Altitude += k.VerticalSpeed * dt
+	}
+
+	return k.Position == target && k.Altitude == targetAltitude
+}
+
+// etaSeconds returns the time needed to fly the distance at the cruise speed of the profile
+func (p motionProfile) etaSeconds(distance float64) float64 {
+	if p.CruiseSpeed <= 0 {
+		return 0
+	}
+	return distance / p.CruiseSpeed
+}
+
+// getKinematicState returns the current motion state of a resource
+func getKinematicState(resourceId string) kinematicState {
+	res, _ := fleet.Resource(resourceId)
+	state := fleet.Motion(resourceId)
+	state.Position = restrictedZone.Point{Lat: res.Latitude, Lon: res.Longitude}
+	if drone, found := fleet.Drone(resourceId); found {
+		state.Altitude = drone.CurrAltitude * restrictedZone.FeetToMeters
+		state.Heading = drone.CurrHeading
+	}
+	return state
+}
+
+// setKinematicState stores the motion state of a resource in the fleet
+func setKinematicState(resourceId string, state kinematicState) {
+	fleet.SetMotion(resourceId, state)
+	setResourcePosition(resourceId, state.Position.Lat, state.Position.Lon, state.Altitude/restrictedZone.FeetToMeters)
+	fleet.UpdateDrone(resourceId, func(drone *models.DroneH3D) {
+		drone.CurrHeading = math.Round(state.Heading)
+	})
+}
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
+
+	"h3d-drone-emulator/models"
+)
+
+var (
+	// ErrInvalidLink is returned when a link command has an unknown state
+	ErrInvalidLink = errors.New("invalid link")
+	// ErrLinkLost is returned when a command is sent to a resource whose link is lost
+	ErrLinkLost = errors.New("link lost")
+	// ErrCommandQueued is returned when a command is queued until the link of the resource is back
+	ErrCommandQueued = errors.New("command queued until the link is back")
+)
+
+// linkTelemetry is a location or a drone status waiting for the link
+type linkTelemetry struct {
+	location *models.ResourceLocation
+	status   *models.DroneStatus
+}
+
+// resourceLink is the link of a resource, lost is incremented on every loss to ignore the failsafe timers of former losses.
+// While the buffer is flushed after a loss, new telemetry keeps being buffered so that it is delivered after the older one.
+type resourceLink struct {
+	models.LinkStatus
+	buffer   []linkTelemetry
+	commands []func() error
+	lost     int
+	flushing bool
+}
+
+// linkStore holds the links of the resources, connected until told otherwise
+type linkStore struct {
+	mutex sync.Mutex
+	links map[string]*resourceLink
+}
+
+var links = &linkStore{links: make(map[string]*resourceLink)}
+
+// getLocked returns the link of the resource, created connected if needed, the caller must hold the lock
+func (s *linkStore) getLocked(resourceId string) *resourceLink {
+	link, found := s.links[resourceId]
+	if !found {
+		link = &resourceLink{LinkStatus: models.LinkStatus{ResourceId: resourceId, State: models.LinkConnected, Since: simClock.Now()}}
+		s.links[resourceId] = link
+	}
+	return link
+}
+
+func (s *linkStore) state(resourceId string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if link, found := s.links[resourceId]; found {
+		return link.State
+	}
+	return models.LinkConnected
+}
+
+func (s *linkStore) status(resourceId string) models.LinkStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	link := s.getLocked(resourceId)
+	status := link.LinkStatus
+	status.Buffered = len(link.buffer)
+	status.QueuedCommands = len(link.commands)
+	return status
+}
+
+// hold keeps the telemetry of a resource whose link is lost or whose buffer is being flushed, dropping the oldest
+// beyond the buffer size, or drops it at random when the link is degraded, from the link stream of the resource so that
+// the other draws of the resource do not change which telemetry is dropped. It returns false when the telemetry can be delivered.
+func (s *linkStore) hold(resourceId string, telemetry linkTelemetry) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	link, found := s.links[resourceId]
+	if !found {
+		return false
+	}
+	if link.State == models.LinkLost || link.flushing {
+		link.buffer = append(link.buffer, telemetry)
+		if overflow := len(link.buffer) - *applicationConfig.LinkBufferSize; overflow > 0 {
+			link.buffer = link.buffer[overflow:]
+			link.Dropped += overflow
+		}
+		return true
+	}
+	switch link.State {
+	case models.LinkDegraded:
+		if resourceStream(resourceId, streamLink).Float64() < *applicationConfig.LinkDegradedLoss {
+			link.Dropped++
+			return true
+		}
+	}
+	return false
+}
+
+// queue runs the command now if the link of the resource is not lost, else queues or rejects it.
+// A command sent while the queued ones wait for the flush of the buffer runs after them.
+func (s *linkStore) queue(resourceId string, command func() error) error {
+	s.mutex.Lock()
+	link, found := s.links[resourceId]
+	if found && link.flushing && len(link.commands) > 0 {
+		link.commands = append(link.commands, command)
+		s.mutex.Unlock()
+		return ErrCommandQueued
+	}
+	if !found || link.State != models.LinkLost {
+		s.mutex.Unlock()
+		return command()
+	}
+	defer s.mutex.Unlock()
+	if strings.ToUpper(*applicationConfig.LinkCommandPolicy) != models.LinkCommandsQueue {
+		return fmt.Errorf("%w: %s", ErrLinkLost, resourceId)
+	}
+	link.commands = append(link.commands, command)
+	return ErrCommandQueued
+}
+
+// setState changes the link of a resource. Losing it schedules the failsafe action,
+// getting it back delivers the buffered telemetry then runs the queued commands, in their order.
+func (s *linkStore) setState(resourceId string, state string) {
+	s.mutex.Lock()
+	link := s.getLocked(resourceId)
+	if link.State == state {
+		s.mutex.Unlock()
+		return
+	}
+	now := simClock.Now()
+	wasLost := link.State == models.LinkLost
+	link.State = state
+	link.Since = now
+	flush := false
+	if state == models.LinkLost {
+		link.lost++
+		timeout := time.Duration(*applicationConfig.LinkLossTimeout * float64(time.Second))
+		failsafeAt := now.Add(timeout)
+		link.FailsafeAt = &failsafeAt
+		link.FailsafeDone = false
+		go s.awaitFailsafe(resourceId, link.lost, timeout)
+	} else if wasLost {
+		link.FailsafeAt = nil
+		link.FailsafeDone = false
+		// A flush still running from a former reconnection delivers the new buffer as well
+		flush = !link.flushing
+		link.flushing = true
+	}
+	s.mutex.Unlock()
+
+	log.Info("Link of %s is %s", resourceId, state)
+	if flush {
+		s.flush(resourceId)
+	}
+}
+
+// flush delivers the buffered telemetry of a resource, including the telemetry buffered meanwhile,
+// then runs its queued commands. It stops if the link is lost again, keeping what is left for the next reconnection.
+func (s *linkStore) flush(resourceId string) {
+	delivered := 0
+	for {
+		s.mutex.Lock()
+		link := s.getLocked(resourceId)
+		if link.State == models.LinkLost || len(link.buffer) == 0 {
+			link.flushing = false
+			var commands []func() error
+			if link.State != models.LinkLost {
+				commands, link.commands = link.commands, nil
+			}
+			s.mutex.Unlock()
+			if delivered > 0 {
+				log.Info("Delivered %d telemetry events of %s buffered while its link was lost", delivered, resourceId)
+			}
+			for _, command := range commands {
+				if err := command(); err != nil {
+					log.Error("Queued command of %s failed: %s", resourceId, err.Error())
+				}
+			}
+			return
+		}
+		buffer := link.buffer
+		link.buffer = nil
+		s.mutex.Unlock()
+
+		for _, telemetry := range buffer {
+			if telemetry.location != nil {
+				deliverLocation(*telemetry.location)
+			} else {
+				deliverDroneStatus(*telemetry.status)
+			}
+		}
+		delivered += len(buffer)
+	}
+}
+
+// awaitFailsafe runs the failsafe action if the link is still lost once the timeout is elapsed
+func (s *linkStore) awaitFailsafe(resourceId string, lost int, timeout time.Duration) {
+	<-simClock.After(timeout)
+	s.mutex.Lock()
+	link := s.getLocked(resourceId)
+	if link.State != models.LinkLost || link.lost != lost {
+		s.mutex.Unlock()
+		return
+	}
+	link.FailsafeDone = true
+	s.mutex.Unlock()
+	runLinkFailsafe(resourceId)
+}
+
+// runLinkFailsafe returns the resource to base, holds it where it is or lands it where it is
+func runLinkFailsafe(resourceId string) {
+	failsafe := strings.ToUpper(*applicationConfig.LinkFailsafe)
+	log.Info("Link of %s lost for too long, running the %s failsafe", resourceId, failsafe)
+	switch failsafe {
+	case models.LinkFailsafeHold:
+		state := fleet.CurrentState(resourceId)
+		if state == models.StatePatrol {
+			fleet.Transition(resourceId, models.StateIdle, "link lost, holding")
+		}
+		// Stops the running activity, if any, where the resource is
+		fleet.StartActivity(resourceId)
+		motion := fleet.Motion(resourceId)
+		motion.GroundSpeed, motion.VerticalSpeed = 0, 0
+		fleet.SetMotion(resourceId, motion)
+	case models.LinkFailsafeLand:
+		forceLanding(resourceId, "link lost, landing in place")
+	default:
+		forceReturnToBase(resourceId, "link lost")
+	}
+}
+
+// isLinkLost tells whether the telemetry of a resource cannot reach the ground
+func isLinkLost(resourceId string) bool {
+	return links.state(resourceId) == models.LinkLost
+}
+
+// sendCommand runs a command for a resource, subject to the state of its link
+func sendCommand(resourceId string, command func() error) error {
+	return links.queue(resourceId, command)
+}
+
+// GetLinkStatus returns the link of a resource
+func GetLinkStatus(resourceId string) (models.LinkStatus, error) {
+	if !fleet.Exists(resourceId) {
+		return models.LinkStatus{}, ErrResourceNotFound
+	}
+	return links.status(resourceId), nil
+}
+
+// SetLinkState changes the link of a resource
+func SetLinkState(resourceId string, command models.LinkCommand) (models.LinkStatus, error) {
+	if !fleet.Exists(resourceId) {
+		return models.LinkStatus{}, ErrResourceNotFound
+	}
+	state := strings.ToUpper(command.State)
+	switch state {
+	case models.LinkConnected, models.LinkDegraded, models.LinkLost:
+	default:
+		return models.LinkStatus{}, fmt.Errorf("%w: state must be one of CONNECTED, DEGRADED or LOST", ErrInvalidLink)
+	}
+	links.setState(resourceId, state)
+	return links.status(resourceId), nil
+}
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
+
+	"h3d-drone-emulator/models"
+	restrictedZone "h3d-drone-emulator/util"
+)
+
+// startResourceMission flies the resource through every waypoint of the mission, in order,
+// then applies the completion behaviour of the mission
+func startResourceMission(mission models.MissionCommand, isVehicle bool, stop <-chan struct{}) error {
+	resourceId := *mission.ResourceId
+	waypoints := mission.GetMissionWaypoints()
+	onComplete := getMissionOnComplete(mission)
+
+	if fleet.CurrentState(resourceId) == models.StatePendingClearance {
+		log.Info("%s waiting for the clearance of mission %s", resourceId, *mission.MissionId)
+		status, waited := waitForClearance(*mission.MissionId, stop)
+		if !waited {
+			return nil
+		}
+		if status == models.ClearanceDenied {
+			if err := fleet.Transition(resourceId, models.StateReturning, "clearance denied"); err != nil {
+				log.Error(err.Error())
+				return err
+			}
+			return goBackToBase(resourceId, stop)
+		}
+		if err := fleet.Transition(resourceId, models.StateDispatching, "clearance granted"); err != nil {
+			log.Error(err.Error())
+			return err
+		}
+	}
+
+	for loop := 0; ; loop++ {
+		for index, waypoint := range waypoints {
+			fleet.UpdateProgress(resourceId, func(progress *models.MissionProgress) {
+				progress.CurrentWaypointIndex = index
+				progress.Loops = loop
+			})
+			if fleet.CurrentState(resourceId) != models.StateEnRoute {
+				if err := fleet.Transition(resourceId, models.StateEnRoute, fmt.Sprintf("leaving for waypoint %d", index)); err != nil {
+					log.Error(err.Error())
+					return err
+				}
+			}
+			if !flyMissionLeg(resourceId, isVehicle, waypoints, index, stop) {
+				log.Info("%s mission completed", resourceId)
+				return nil
+			}
+			if waypoint.LoiterSeconds > 0 {
+				if err := fleet.Transition(resourceId, models.StateOnScene, fmt.Sprintf("loitering at waypoint %d", index)); err != nil {
+					log.Error(err.Error())
+					return err
+				}
+				select {
+				case <-stop:
+					log.Info("%s mission completed", resourceId)
+					return nil
+				case <-simClock.After(time.Duration(waypoint.LoiterSeconds) * time.Second):
+				}
+			}
+		}
+		if onComplete != models.MissionCompleteLoop {
+			break
+		}
+	}
+
+	if onComplete == models.MissionCompleteRTL {
+		if err := fleet.Transition(resourceId, models.StateReturning, "mission completed"); err != nil {
+			log.Error(err.Error())
+			return err
+		}
+		return goBackToBase(resourceId, stop)
+	}
+
+	// Hold at the last waypoint until the mission is stopped
+	if fleet.CurrentState(resourceId) != models.StateOnScene {
+		if err := fleet.Transition(resourceId, models.StateOnScene, "holding at last waypoint"); err != nil {
+			log.Error(err.Error())
+			return err
+		}
+	}
+	state, _ := fleet.State(resourceId)
+	log.Info("%s attending to mission %s", resourceId, state.MissionId)
+	<-stop
+	log.Info("%s mission completed", resourceId)
+	return nil
+}
+
+// flyMissionLeg flies the resource to the waypoint of the given index and keeps the progress up to date,
+// it returns false if the mission is stopped
+func flyMissionLeg(resourceId string, isVehicle bool, waypoints []models.MissionWaypoint, index int, stop <-chan struct{}) bool {
+	waypoint := waypoints[index]
+	target := restrictedZone.Point{Lat: waypoint.Latitude, Lon: waypoint.Longitude}
+	profile := getWaypointProfile(isVehicle, waypoint)
+	altitude := float64(*applicationConfig.Altitude) * restrictedZone.FeetToMeters
+	if waypoint.Altitude != nil {
+		altitude = *waypoint.Altitude * restrictedZone.FeetToMeters
+	}
+	if isVehicle {
+		altitude = 0
+	}
+
+	// Distance and time of the following legs
+	restDistance, restEta := 0.0, 0.0
+	for i := index + 1; i < len(waypoints); i++ {
+		from := restrictedZone.Point{Lat: waypoints[i-1].Latitude, Lon: waypoints[i-1].Longitude}
+		to := restrictedZone.Point{Lat: waypoints[i].Latitude, Lon: waypoints[i].Longitude}
+		distance := restrictedZone.DistanceMeters(from, to)
+		restDistance += distance
+		restEta += getWaypointProfile(isVehicle, waypoints[i]).etaSeconds(distance)
+	}
+
+	log.Info("Produce mission for ID: %s to waypoint %d: %f %f", resourceId, index, waypoint.Latitude, waypoint.Longitude)
+	return flyTo(resourceId, isVehicle, target, altitude, profile, stop, func(state kinematicState) {
+		remaining := restrictedZone.DistanceMeters(state.Position, target)
+		fleet.UpdateProgress(resourceId, func(progress *models.MissionProgress) {
+			progress.DistanceRemainingMeters = remaining + restDistance
+			progress.EtaSeconds = profile.etaSeconds(remaining) + restEta
+		})
+	})
+}
+
+// getWaypointProfile returns the motion profile of the leg to the waypoint, its speed is in meters per second
+func getWaypointProfile(isVehicle bool, waypoint models.MissionWaypoint) motionProfile {
+	speed := 0.0
+	if waypoint.Speed != nil {
+		speed = *waypoint.Speed
+	}
+	return getMotionProfile(isVehicle, speed)
+}
+
+// flyTo moves the resource to the target with the kinematic model and publishes its location
+// every location interval, it returns false if the activity is stopped
+func flyTo(resourceId string, isVehicle bool, target restrictedZone.Point, altitude float64, profile motionProfile, stop <-chan struct{}, onTick func(state kinematicState)) bool {
+	return flyGuarded(resourceId, isVehicle, target, altitude, profile, stop, onTick, true)
+}
+
+// flyGuarded flies to the target without entering the zones with a HOLD or REROUTE policy.
+// The resource holds at the boundary of a zone until it is deactivated, or flies around it if it can be rerouted.
+// Ground vehicles are not concerned by no-fly zones.
+func flyGuarded(resourceId string, isVehicle bool, target restrictedZone.Point, altitude float64, profile motionProfile, stop <-chan struct{}, onTick func(state kinematicState), canReroute bool) bool {
+	tick := time.Duration(*applicationConfig.SimTick * float64(time.Second))
+	state := getKinematicState(resourceId)
+	sinceLocation := 0.0
+	last := simClock.Now()
+	for {
+		var now time.Time
+		select {
+		case <-stop:
+			return false
+		case now = <-simClock.After(tick):
+		}
+		// A clock step may elapse several ticks at once
+		elapsed := now.Sub(last).Seconds()
+		last = now
+		arrived, blocked := false, false
+		var block geofenceBlock
+		for remaining := elapsed; remaining > 0 && !arrived && !blocked; remaining -= tick.Seconds() {
+			previous := state.Position
+			arrived = state.advance(target, altitude, profile, math.Min(remaining, tick.Seconds()))
+			if !isVehicle {
+				if block, blocked = findGeofenceBlock(resourceId, previous, state.Position, now); blocked {
+					state.Position = block.stop
+					state.GroundSpeed = 0
+					arrived = false
+				}
+			}
+		}
+		setKinematicState(resourceId, state)
+		if onTick != nil {
+			onTick(state)
+		}
+		sinceLocation += elapsed
+		if arrived || sinceLocation >= *applicationConfig.LocationInterval {
+			publishLocation(resourceId, isVehicle)
+			sinceLocation = 0
+		}
+		if arrived {
+			geofence.release(resourceId)
+			return true
+		}
+		if !blocked {
+			geofence.release(resourceId)
+			continue
+		}
+		if block.policy == models.GeofencePolicyReroute && canReroute {
+			if detour, found := planGeofenceDetour(resourceId, state.Position, target, now); found {
+				geofence.block(resourceId, block, models.GeofenceReroute)
+				for _, corner := range detour[1 : len(detour)-1] {
+					if !flyGuarded(resourceId, isVehicle, corner, altitude, profile, stop, onTick, false) {
+						return false
+					}
+				}
+				state = getKinematicState(resourceId)
+				last = simClock.Now()
+				continue
+			}
+		}
+		geofence.block(resourceId, block, models.GeofenceHold)
+	}
+}
+
+// getMissionOnComplete returns the completion behaviour of the mission, HOLD by default
+func getMissionOnComplete(mission models.MissionCommand) string {
+	if mission.OnComplete == "" {
+		return models.MissionCompleteHold
+	}
+	return mission.OnComplete
+}
+
+// validateMission checks the waypoints and the completion behaviour of a mission command
+func validateMission(mission models.MissionCommand) error {
+	if mission.ResourceId == nil {
+		return fmt.Errorf("%w: resourceId is required", ErrInvalidMission)
+	}
+	if len(mission.GetMissionWaypoints()) == 0 {
+		return fmt.Errorf("%w: at least one [lat, lon] waypoint is required", ErrInvalidMission)
+	}
+	switch getMissionOnComplete(mission) {
+	case models.MissionCompleteRTL, models.MissionCompleteHold, models.MissionCompleteLoop:
+	default:
+		return fmt.Errorf("%w: onComplete must be one of RTL, HOLD or LOOP", ErrInvalidMission)
+	}
+	return nil
+}
+
+func goBackToBase(resourceId string, stop <-chan struct{}) error {
+	resource, found := fleet.Resource(resourceId)
+	if !found {
+		return ErrResourceNotFound
+	}
+	base := restrictedZone.Point{Lat: resource.BaseLatitude, Lon: resource.BaseLongitude}
+	profile := getMotionProfile(resource.IsVehicle, 0)
+	altitude := float64(*applicationConfig.Altitude) * restrictedZone.FeetToMeters
+	if resource.IsVehicle {
+		altitude = 0
+	}
+
+	// Fly back at cruise altitude then land
+	log.Info("Back to base ID: %s: %f %f", resourceId, base.Lat, base.Lon)
+	if !flyTo(resourceId, resource.IsVehicle, base, altitude, profile, stop, nil) || !flyTo(resourceId, resource.IsVehicle, base, 0, profile, stop, nil) {
+		log.Info("%s received start mission, terminating return to base", resourceId)
+		return nil
+	}
+	log.Info("%s has reached base", resourceId)
+
+	if err := fleet.Transition(resourceId, models.StateLanded, "reached base"); err != nil {
+		log.Error(err.Error())
+		return err
+	}
+	if drone, found := fleet.Drone(resourceId); found && drone.BattLevel < 100 {
+		// The battery loop resumes the patrol or idles the drone once charged
+		fleet.Transition(resourceId, models.StateCharging, "charging at base")
+	} else if len(fleet.Patrol(resourceId)) > 0 {
+		fleet.Transition(resourceId, models.StatePatrol, "resuming patrol")
+	} else {
+		fleet.Transition(resourceId, models.StateIdle, "at base")
+	}
+
+	return nil
+}
+
+// forceReturnToBase sends a resource on patrol or on a mission back to base
+func forceReturnToBase(resourceId string, reason string) {
+	state := fleet.CurrentState(resourceId)
+	if !isOnMission(state) && state != models.StatePatrol {
+		return
+	}
+	if err := fleet.Transition(resourceId, models.StateReturning, reason); err != nil {
+		log.Error(err.Error())
+		return
+	}
+	stop, _ := fleet.StartActivity(resourceId)
+	go goBackToBase(resourceId, stop)
+}
+
+// forceLanding stops the activity of a resource in the FAULT state, a flying drone lands where it is
+func forceLanding(resourceId string, reason string) {
+	if fleet.CurrentState(resourceId) == models.StateFault {
+		return
+	}
+	if err := fleet.Transition(resourceId, models.StateFault, reason); err != nil {
+		log.Error(err.Error())
+		return
+	}
+	stop, _ := fleet.StartActivity(resourceId)
+	if drone, found := fleet.Drone(resourceId); found && drone.CurrAltitude > 0 {
+		go landInPlace(resourceId, stop)
+	}
+}
+
+// landInPlace descends the drone vertically to the ground where it is
+func landInPlace(droneId string, stop <-chan struct{}) {
+	state := getKinematicState(droneId)
+	if !flyTo(droneId, false, state.Position, 0, getMotionProfile(false, 0), stop, nil) {
+		return
+	}
+	log.Info("%s has landed in place", droneId)
+	if err := fleet.Transition(droneId, models.StateLanded, "forced landing"); err != nil {
+		log.Error(err.Error())
+	}
+}
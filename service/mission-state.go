@@ -0,0 +1,57 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"h3d-drone-emulator/models"
+)
+
+// maxStateTransitions is the number of transitions kept in the history of a resource
+const maxStateTransitions = 20
+
+// ErrResourceNotFound is returned when a command targets an unknown resource
+var ErrResourceNotFound = errors.New("resource not found")
+
+// ErrInvalidMission is returned when a mission command is incomplete
+var ErrInvalidMission = errors.New("invalid mission")
+
+// TransitionError is returned when a command is not allowed in the current state of a resource
+type TransitionError struct {
+	ResourceId string
+	From       models.ResourceState
+	To         models.ResourceState
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("resource %s cannot go from %s to %s", e.ResourceId, e.From, e.To)
+}
+
+// resourceTransitions lists the states reachable from each state
+var resourceTransitions = map[models.ResourceState][]models.ResourceState{
+	models.StateIdle:             {models.StateCharging, models.StatePatrol, models.StatePendingClearance, models.StateDispatching, models.StateFault},
+	models.StateCharging:         {models.StateIdle, models.StatePatrol, models.StatePendingClearance, models.StateDispatching, models.StateFault},
+	models.StatePatrol:           {models.StateIdle, models.StatePendingClearance, models.StateDispatching, models.StateReturning, models.StateFault},
+	models.StatePendingClearance: {models.StateDispatching, models.StateReturning, models.StateFault},
+	models.StateDispatching:      {models.StateEnRoute, models.StateReturning, models.StateFault},
+	models.StateEnRoute:          {models.StateOnScene, models.StateReturning, models.StateFault},
+	models.StateOnScene:          {models.StateEnRoute, models.StateReturning, models.StateFault},
+	models.StateReturning:        {models.StateLanded, models.StatePendingClearance, models.StateDispatching, models.StateFault},
+	models.StateLanded:           {models.StateIdle, models.StateCharging, models.StatePatrol, models.StatePendingClearance, models.StateDispatching, models.StateFault},
+	models.StateFault:            {models.StateLanded, models.StateIdle},
+}
+
+// canTransition returns true if a resource can go from one state to the other
+func canTransition(from models.ResourceState, to models.ResourceState) bool {
+	for _, state := range resourceTransitions[from] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+// isOnMission returns true if the state is one of the mission states
+func isOnMission(state models.ResourceState) bool {
+	return state == models.StatePendingClearance || state == models.StateDispatching || state == models.StateEnRoute || state == models.StateOnScene
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sync"
+)
+
+// seededRandom is a random source safe for concurrent use
+type seededRandom struct {
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+func newSeededRandom(seed int64) *seededRandom {
+	return &seededRandom{rand: rand.New(rand.NewSource(seed))}
+}
+
+// Intn returns a random int in [0, n)
+func (r *seededRandom) Intn(n int) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.rand.Intn(n)
+}
+
+// Float64 returns a random float in [0, 1)
+func (r *seededRandom) Float64() float64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.rand.Float64()
+}
+
+// NormFloat64 returns a normally distributed float with mean 0 and standard deviation 1
+func (r *seededRandom) NormFloat64() float64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.rand.NormFloat64()
+}
+
+var simSeed int64
+var resourceRandoms = map[string]*seededRandom{}
+var resourceRandomsMutex sync.Mutex
+
+// initRandom seeds the random sources of the run
+func initRandom(seed int64) {
+	resourceRandomsMutex.Lock()
+	defer resourceRandomsMutex.Unlock()
+	simSeed = seed
+	resourceRandoms = map[string]*seededRandom{}
+}
+
+// The named streams of a resource, apart from its main stream so that drawing for one concern does not shift the others
+const (
+	streamSensors = "sensors"
+	streamFaults  = "faults"
+	streamLink    = "link"
+)
+
+// resourceRandom returns the random source of a resource. Each resource has its own stream derived
+// from the run seed and its ID, so that its values do not depend on the activity of the other resources.
+func resourceRandom(resourceId string) *seededRandom {
+	return resourceStream(resourceId, "")
+}
+
+// resourceStream returns a named random source of a resource, the main one for an empty name
+func resourceStream(resourceId string, stream string) *seededRandom {
+	key := resourceId
+	if stream != "" {
+		key += "/" + stream
+	}
+	resourceRandomsMutex.Lock()
+	defer resourceRandomsMutex.Unlock()
+	random, found := resourceRandoms[key]
+	if !found {
+		hash := fnv.New64a()
+		hash.Write([]byte(key))
+		random = newSeededRandom(simSeed ^ int64(hash.Sum64()))
+		resourceRandoms[key] = random
+	}
+	return random
+}
+
+// randomResourceInt returns a random int in [min, max) from the stream of the resource
+func randomResourceInt(resourceId string, min int, max int) int {
+	return resourceRandom(resourceId).Intn(max-min) + min
+}
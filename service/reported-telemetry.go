@@ -0,0 +1,60 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	restrictedZone "h3d-drone-emulator/util"
+)
+
+// reportedTelemetry is what a resource last reported in its telemetry. The reads return it
+// instead of measuring again, so that they draw no random value and shift no later telemetry.
+type reportedTelemetry struct {
+	position    restrictedZone.Point
+	altitude    float64
+	locationAt  time.Time
+	heading     int
+	temperature string
+	statusAt    time.Time
+}
+
+// reportedStore holds the last reported telemetry of each resource
+type reportedStore struct {
+	mutex   sync.Mutex
+	reports map[string]reportedTelemetry
+}
+
+var reports = &reportedStore{reports: make(map[string]reportedTelemetry)}
+
+// setLocation records the position and the altitude in feet reported at a time
+func (s *reportedStore) setLocation(resourceId string, position restrictedZone.Point, altitude float64, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	report := s.reports[resourceId]
+	report.position, report.altitude, report.locationAt = position, altitude, now
+	s.reports[resourceId] = report
+}
+
+// setStatus records the heading and the temperature reported at a time
+func (s *reportedStore) setStatus(resourceId string, heading int, temperature string, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	report := s.reports[resourceId]
+	report.heading, report.temperature, report.statusAt = heading, temperature, now
+	s.reports[resourceId] = report
+}
+
+// get returns the last reported telemetry of a resource, whose times are zero for what it has not reported yet
+func (s *reportedStore) get(resourceId string) reportedTelemetry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.reports[resourceId]
+}
+
+// lastPosition returns the last reported position of a resource, the true one if it has reported none yet
+func (s *reportedStore) lastPosition(resourceId string, position restrictedZone.Point) restrictedZone.Point {
+	if report := s.get(resourceId); !report.locationAt.IsZero() {
+		return report.position
+	}
+	return position
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"math"
+	"time"
+
+	restrictedZone "h3d-drone-emulator/util"
+)
+
+// maxExactOrderStops is the largest number of intermediate stops ordered exactly, larger routes are ordered by heuristics
+const maxExactOrderStops = 12
+
+// getHopCosts returns the flight time in seconds between every pair of stops, clearance waits included,
+// so that the best order avoids the no-fly zones when going around them is quicker
+func getHopCosts(routeType string, stops []restrictedZone.Point, zones []restrictedZone.RestrictedZone, departure time.Time, speed float64) [][]float64 {
+	start := departure.Add(time.Duration(*applicationConfig.DispatchTime) * time.Second)
+	costs := make([][]float64, len(stops))
+	for i := range stops {
+		costs[i] = make([]float64, len(stops))
+		for j := range stops {
+			// The route never comes back to the origin nor leaves the destination
+			if i == j || j == 0 || i == len(stops)-1 {
+				continue
+			}
+			costs[i][j] = planHop(routeType, stops[i], stops[j], zones, start, speed).flightTime(speed)
+		}
+	}
+	return costs
+}
+
+// solveBestOrder returns the order of the stops minimizing the total cost, from the first stop to the last one,
+// which both keep their place
+func solveBestOrder(costs [][]float64) []int {
+	if len(costs)-2 <= maxExactOrderStops {
+		return solveExactOrder(costs)
+	}
+	return improveOrder(costs, solveNearestOrder(costs))
+}
+
+// solveExactOrder solves the order with the Held-Karp dynamic programming algorithm
+func solveExactOrder(costs [][]float64) []int {
+	n := len(costs)
+	m := n - 2
+	if m <= 0 {
+		order := make([]int, n)
+		for i := range order {
+			order[i] = i
+		}
+		return order
+	}
+
+	// best[mask][k] is the cost of the best path from the origin through the stops of mask, ending on stop k+1
+	best := make([][]float64, 1<<m)
+	parent := make([][]int, 1<<m)
+	for mask := range best {
+		best[mask] = make([]float64, m)
+		parent[mask] = make([]int, m)
+		for k := range best[mask] {
+			best[mask][k] = math.Inf(1)
+			parent[mask][k] = -1
+		}
+	}
+	for k := 0; k < m; k++ {
+		best[1<<k][k] = costs[0][k+1]
+	}
+	for mask := 1; mask < 1<<m; mask++ {
+		for k := 0; k < m; k++ {
+			if mask&(1<<k) == 0 || math.IsInf(best[mask][k], 1) {
+				continue
+			}
+			for next := 0; next < m; next++ {
+				if mask&(1<<next) != 0 {
+					continue
+				}
+				cost := best[mask][k] + costs[k+1][next+1]
+				if cost < best[mask|1<<next][next] {
+					best[mask|1<<next][next] = cost
+					parent[mask|1<<next][next] = k
+				}
+			}
+		}
+	}
+
+	full := 1<<m - 1
+	last := 0
+	for k := 1; k < m; k++ {
+		if best[full][k]+costs[k+1][n-1] < best[full][last]+costs[last+1][n-1] {
+			last = k
+		}
+	}
+	order := []int{n - 1}
+	for mask, k := full, last; k >= 0; {
+		order = append([]int{k + 1}, order...)
+		mask, k = mask&^(1<<k), parent[mask][k]
+	}
+	return append([]int{0}, order...)
+}
+
+// solveNearestOrder builds an order by always going to the nearest stop not visited yet
+func solveNearestOrder(costs [][]float64) []int {
+	n := len(costs)
+	visited := make([]bool, n)
+	order := []int{0}
+	visited[0] = true
+	for len(order) < n-1 {
+		current := order[len(order)-1]
+		next := -1
+		for j := 1; j < n-1; j++ {
+			if !visited[j] && (next < 0 || costs[current][j] < costs[current][next]) {
+				next = j
+			}
+		}
+		visited[next] = true
+		order = append(order, next)
+	}
+	return append(order, n-1)
+}
+
+// improveOrder applies 2-opt moves, reversing parts of the order while it lowers the total cost
+func improveOrder(costs [][]float64, order []int) []int {
+	total := getOrderCost(costs, order)
+	for improved := true; improved; {
+		improved = false
+		for i := 1; i < len(order)-2; i++ {
+			for j := i + 1; j < len(order)-1; j++ {
+				candidate := append([]int{}, order...)
+				for a, b := i, j; a < b; a, b = a+1, b-1 {
+					candidate[a], candidate[b] = candidate[b], candidate[a]
+				}
+				if cost := getOrderCost(costs, candidate); cost < total {
+					order, total, improved = candidate, cost, true
+				}
+			}
+		}
+	}
+	return order
+}
+
+func getOrderCost(costs [][]float64, order []int) float64 {
+	total := 0.0
+	for i := 1; i < len(order); i++ {
+		total += costs[order[i-1]][order[i]]
+	}
+	return total
+}
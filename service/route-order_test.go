@@ -0,0 +1,75 @@
+package service
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// orderCost returns the total cost of going through the stops in the order
+func orderCost(costs [][]float64, order []int) float64 {
+	total := 0.0
+	for i := 1; i < len(order); i++ {
+		total += costs[order[i-1]][order[i]]
+	}
+	return total
+}
+
+// bruteForceOrderCost returns the lowest cost of every order of the intermediate stops
+func bruteForceOrderCost(costs [][]float64) float64 {
+	n := len(costs)
+	stops := make([]int, 0, n)
+	for i := 1; i < n-1; i++ {
+		stops = append(stops, i)
+	}
+	best := math.Inf(1)
+	var permute func(k int)
+	permute = func(k int) {
+		if k == len(stops) {
+			order := append(append([]int{0}, stops...), n-1)
+			best = math.Min(best, orderCost(costs, order))
+			return
+		}
+		for i := k; i < len(stops); i++ {
+			stops[k], stops[i] = stops[i], stops[k]
+			permute(k + 1)
+			stops[k], stops[i] = stops[i], stops[k]
+		}
+	}
+	permute(0)
+	return best
+}
+
+func TestSolveExactOrderMatchesBruteForce(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for stops := 0; stops <= 6; stops++ {
+		for trial := 0; trial < 20; trial++ {
+			n := stops + 2
+			// Asymmetric costs, as clearance waits depend on the direction
+			costs := make([][]float64, n)
+			for i := range costs {
+				costs[i] = make([]float64, n)
+				for j := range costs[i] {
+					if i != j {
+						costs[i][j] = float64(random.Intn(100))
+					}
+				}
+			}
+
+			order := solveExactOrder(costs)
+			if len(order) != n || order[0] != 0 || order[n-1] != n-1 {
+				t.Fatalf("%d stops: order %v does not go from the first to the last stop", stops, order)
+			}
+			seen := make(map[int]bool)
+			for _, stop := range order {
+				if seen[stop] {
+					t.Fatalf("%d stops: order %v visits %d twice", stops, order, stop)
+				}
+				seen[stop] = true
+			}
+			if got, want := orderCost(costs, order), bruteForceOrderCost(costs); got != want {
+				t.Errorf("%d stops: cost of %v = %g, want %g", stops, order, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
+
+	"h3d-drone-emulator/models"
+	restrictedZone "h3d-drone-emulator/util"
+)
+
+// directRouteWaypoints is the number of points describing a direct route
+const directRouteWaypoints = 10
+
+// maxRouteStops and maxOptimizedRouteStops bound the number of stops of a route, without and with best order
+const (
+	maxRouteStops          = 150
+	maxOptimizedRouteStops = 20
+)
+
+// ErrInvalidRoute is returned when a route request has invalid parameters
+var ErrInvalidRoute = errors.New("invalid route request")
+
+// RouteParameterError names the invalid parameter of a route request
+type RouteParameterError struct {
+	Parameter string
+	Value     string
+	Reason    string
+}
+
+func (e *RouteParameterError) Error() string {
+	return fmt.Sprintf("invalid %s %q: %s", e.Parameter, e.Value, e.Reason)
+}
+
+func (e *RouteParameterError) Unwrap() error {
+	return ErrInvalidRoute
+}
+
+// routeOptions are the validated parameters of a route request
+type routeOptions struct {
+	stops               []restrictedZone.Point
+	routeType           string
+	travelMode          string
+	computeBestOrder    bool
+	routeRepresentation string
+	resourceId          string
+}
+
+// routePlan is a path with the no-fly zones it crosses
+type routePlan struct {
+	path           []restrictedZone.Point
+	distance       float64
+	clearanceZones []models.ClearanceZone
+}
+
+// validateRouteRequest checks the route request and fills in the defaults:
+// fastest route type, drone travel mode, polyline representation
+func validateRouteRequest(request models.RouteRequest) (routeOptions, error) {
+	options := routeOptions{
+		routeType:           valueOrDefault(request.RouteType, models.RouteTypeFastest),
+		travelMode:          valueOrDefault(request.TravelMode, models.TravelModeDrone),
+		routeRepresentation: valueOrDefault(request.RouteRepresentation, models.RouteRepresentationPolyline),
+		resourceId:          request.ResourceId,
+	}
+
+	stops, err := parseRouteStops(request.Query)
+	if err != nil {
+		return options, err
+	}
+	options.stops = stops
+
+	switch options.routeType {
+	case models.RouteTypeFastest, models.RouteTypeShortest, models.RouteTypeAvoid:
+	default:
+		return options, &RouteParameterError{Parameter: "routeType", Value: request.RouteType, Reason: "must be one of fastest, shortest or avoid"}
+	}
+	switch options.travelMode {
+	case models.TravelModeDrone, models.TravelModeVehicle:
+	default:
+		return options, &RouteParameterError{Parameter: "travelMode", Value: request.TravelMode, Reason: "must be one of drone or vehicle"}
+	}
+	switch options.routeRepresentation {
+	case models.RouteRepresentationPolyline, models.RouteRepresentationSummaryOnly, models.RouteRepresentationNone:
+	default:
+		return options, &RouteParameterError{Parameter: "routeRepresentation", Value: request.RouteRepresentation, Reason: "must be one of polyline, summaryOnly or none"}
+	}
+	if request.ComputeBestOrder != "" {
+		computeBestOrder, err := strconv.ParseBool(request.ComputeBestOrder)
+		if err != nil {
+			return options, &RouteParameterError{Parameter: "computeBestOrder", Value: request.ComputeBestOrder, Reason: "must be true or false"}
+		}
+		options.computeBestOrder = computeBestOrder
+	}
+	if options.computeBestOrder && len(options.stops) > maxOptimizedRouteStops {
+		return options, &RouteParameterError{Parameter: "computeBestOrder", Value: request.ComputeBestOrder, Reason: fmt.Sprintf("needs at most %d stops", maxOptimizedRouteStops)}
+	}
+	return options, nil
+}
+
+func valueOrDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// parseRouteStops parses a "lat,lon:lat,lon[:lat,lon...]" query into the stops of the route,
+// from the origin to the destination
+func parseRouteStops(query string) ([]restrictedZone.Point, error) {
+	parts := strings.Split(query, ":")
+	if query == "" || len(parts) < 2 {
+		return nil, &RouteParameterError{Parameter: "query", Value: query, Reason: "must be at least origin:destination, as lat,lon:lat,lon"}
+	}
+	if len(parts) > maxRouteStops {
+		return nil, &RouteParameterError{Parameter: "query", Value: query, Reason: fmt.Sprintf("must have at most %d stops", maxRouteStops)}
+	}
+	stops := make([]restrictedZone.Point, 0, len(parts))
+	for _, part := range parts {
+		coordinates := strings.Split(part, ",")
+		if len(coordinates) != 2 {
+			return nil, &RouteParameterError{Parameter: "query", Value: query, Reason: fmt.Sprintf("%q is not a lat,lon point", part)}
+		}
+		lat, latErr := strconv.ParseFloat(strings.TrimSpace(coordinates[0]), 64)
+		lon, lonErr := strconv.ParseFloat(strings.TrimSpace(coordinates[1]), 64)
+		if latErr != nil || lonErr != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			return nil, &RouteParameterError{Parameter: "query", Value: query, Reason: fmt.Sprintf("%q is not a valid lat,lon point", part)}
+		}
+		stops = append(stops, restrictedZone.Point{Lat: lat, Lon: lon})
+	}
+	return stops, nil
+}
+
+// getActiveZones returns the zones active at some time of the interval
+func getActiveZones(zones []restrictedZone.RestrictedZone, from, to time.Time) []restrictedZone.RestrictedZone {
+	active := make([]restrictedZone.RestrictedZone, 0, len(zones))
+	for _, zone := range zones {
+		if zone.IsActive(from, to) {
+			active = append(active, zone)
+		}
+	}
+	return active
+}
+
+// planPath measures the path and finds the no-fly zones it crosses, flying at the speed in miles per hour
+func planPath(path []restrictedZone.Point, zones []restrictedZone.RestrictedZone, departure time.Time, speed float64) routePlan {
+	plan := routePlan{path: path, clearanceZones: make([]models.ClearanceZone, 0)}
+	for i := 1; i < len(path); i++ {
+		legStart := departure.Add(time.Duration(plan.distance / restrictedZone.ConvertMphToMps(speed) * float64(time.Second)))
+		if len(zones) > 0 {
+			_, legZones := restrictedZone.IsPathInRestrictedZone(path[i-1], path[i], zones, legStart, speed)
+			plan.clearanceZones = mergeClearanceZones(plan.clearanceZones, legZones)
+		}
+		plan.distance += restrictedZone.DistanceMeters(path[i-1], path[i])
+	}
+	return plan
+}
+
+// mergeClearanceZones adds the zones crossed later on the route to the zones, a zone crossed again keeps
+// its first entry, its last exit and the distance flown inside over all its crossings
+func mergeClearanceZones(zones, later []models.ClearanceZone) []models.ClearanceZone {
+	for _, zone := range later {
+		merged := false
+		for i := range zones {
+			if zones[i].ID == zone.ID {
+				zones[i].ExitTime = zone.ExitTime
+				zones[i].DistanceInZone += zone.DistanceInZone
+				zones[i].Crossings = append(zones[i].Crossings, zone.Crossings...)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			zone.Crossings = append([]models.ZoneCrossing{}, zone.Crossings...)
+			zones = append(zones, zone)
+		}
+	}
+	return zones
+}
+
+// planAvoidance plans a detour around the zones, the zones containing the source or the destination
+// are still crossed. Without any detour around the zones, the direct path is planned.
+func planAvoidance(source, destination restrictedZone.Point, zones []restrictedZone.RestrictedZone, departure time.Time, speed float64) routePlan {
+	path, found := restrictedZone.PlanAvoidanceRoute(source, destination, zones, *applicationConfig.AvoidanceBuffer)
+	if !found {
+		log.Info("No detour around the no-fly zones from %v to %v, flying direct", source, destination)
+		path = []restrictedZone.Point{source, destination}
+	}
+	return planPath(path, zones, departure, speed)
+}
+
+// flightTime returns the time in seconds to fly the plan, waiting for clearance if needed
+func (p routePlan) flightTime(speed float64) float64 {
+	return p.cruiseTime(speed) + getClearanceTime(p.clearanceZones)
+}
+
+// cruiseTime returns the time in seconds flown along the path, without the clearance waits
+func (p routePlan) cruiseTime(speed float64) float64 {
+	return p.distance / restrictedZone.ConvertMphToMps(speed)
+}
+
+// planHop plans the route between two stops.
+// Shortest routes fly direct and request clearance for the no-fly zones crossed, avoid routes go around the zones
+// and fastest routes take the quickest of both.
+func planHop(routeType string, source, destination restrictedZone.Point, zones []restrictedZone.RestrictedZone, departure time.Time, speed float64) routePlan {
+	switch routeType {
+	case models.RouteTypeShortest:
+		return planPath([]restrictedZone.Point{source, destination}, zones, departure, speed)
+	case models.RouteTypeAvoid:
+		return planAvoidance(source, destination, zones, departure, speed)
+	}
+	plan := planPath([]restrictedZone.Point{source, destination}, zones, departure, speed)
+	if len(plan.clearanceZones) > 0 {
+		if detour := planAvoidance(source, destination, zones, departure, speed); detour.flightTime(speed) < plan.flightTime(speed) {
+			return detour
+		}
+	}
+	return plan
+}
+
+// PlanRoute computes the route of the request, with one leg per hop between two stops.
+// The resource is dispatched before the first leg. Ground vehicles are not concerned by no-fly zones.
+func PlanRoute(rc *models.RequestContext, request models.RouteRequest) (models.RouteResponse, error) {
+	options, err := validateRouteRequest(request)
+	if err != nil {
+		return models.RouteResponse{}, err
+	}
+	departure := simClock.Now()
+
+	speed := float64(*applicationConfig.DroneSpeed)
+	snapshot := zones.snapshot()
+	activeZones := []restrictedZone.RestrictedZone{}
+	if options.travelMode == models.TravelModeVehicle {
+		speed = float64(*applicationConfig.VehicleSpeed)
+	} else {
+		// Detours and clearance are flown within a few times the direct flight time
+		horizon := 3*getDirectFlightTime(options.stops, speed) + float64(*applicationConfig.DispatchTime+len(options.stops)**applicationConfig.ClearanceTime)
+		activeZones = getActiveZones(snapshot.zones, departure, departure.Add(time.Duration(horizon)*time.Second))
+	}
+
+	stops := options.stops
+	response := models.RouteResponse{ZoneSnapshotVersion: snapshot.version, ZoneSnapshotStale: snapshot.stale}
+	if options.computeBestOrder && len(stops) > 2 {
+		order := solveBestOrder(getHopCosts(options.routeType, stops, activeZones, departure, speed))
+		stops = make([]restrictedZone.Point, 0, len(order))
+		for optimizedIndex, providedIndex := range order {
+			stops = append(stops, options.stops[providedIndex])
+			// Like the intermediate waypoints they describe, the indexes exclude the origin
+			if optimizedIndex > 0 && optimizedIndex < len(order)-1 {
+				response.OptimizedWaypoints = append(response.OptimizedWaypoints, models.OptimizedWaypoint{
+					ProvidedIndex:  providedIndex - 1,
+					OptimizedIndex: optimizedIndex - 1,
+				})
+			}
+		}
+		sort.Slice(response.OptimizedWaypoints, func(i, j int) bool {
+			return response.OptimizedWaypoints[i].ProvidedIndex < response.OptimizedWaypoints[j].ProvidedIndex
+		})
+	}
+
+	summary := models.RouteSummary{DepartureTime: departure.Truncate(time.Second), ClearanceZones: make([]models.ClearanceZone, 0)}
+	elapsed := float64(*applicationConfig.DispatchTime)
+	// The battery only drains in flight, not while the resource is dispatched nor while it waits for clearance
+	cruised := 0.0
+	route := models.Route{}
+	for i := 1; i < len(stops); i++ {
+		legDeparture := departure.Add(time.Duration(elapsed * float64(time.Second)))
+		plan := planHop(options.routeType, stops[i-1], stops[i], activeZones, legDeparture, speed)
+		legTime := plan.flightTime(speed)
+		if i == 1 {
+			legTime += float64(*applicationConfig.DispatchTime)
+			legDeparture = departure
+		}
+		elapsed += plan.flightTime(speed)
+		cruised += plan.cruiseTime(speed)
+		legArrival := departure.Add(time.Duration(elapsed * float64(time.Second)))
+
+		legSummary := models.LegSummary{
+			LengthInMeters:                   int(plan.distance),
+			TravelTimeInSeconds:              int(legTime),
+			DepartureTime:                    legDeparture.Truncate(time.Second),
+			ArrivalTime:                      legArrival.Truncate(time.Second),
+			ClearanceRequired:                len(plan.clearanceZones) > 0,
+			RemainingOperationTimeAtLocation: int(GetRemainingOperationTimeAtLocation(options.resourceId, cruised)),
+			ClearanceZones:                   plan.clearanceZones,
+		}
+		switch options.routeRepresentation {
+		case models.RouteRepresentationPolyline:
+			route.Legs = append(route.Legs, models.Leg{Summary: legSummary, Points: getPlanWaypoints(plan, legDeparture, legArrival)})
+		case models.RouteRepresentationSummaryOnly:
+			route.Legs = append(route.Legs, models.Leg{Summary: legSummary})
+		}
+
+		summary.LengthInMeters += legSummary.LengthInMeters
+		summary.ClearanceRequired = summary.ClearanceRequired || legSummary.ClearanceRequired
+		summary.ClearanceZones = mergeClearanceZones(summary.ClearanceZones, plan.clearanceZones)
+		summary.ArrivalTime = legSummary.ArrivalTime
+		summary.RemainingOperationTimeAtLocation = legSummary.RemainingOperationTimeAtLocation
+	}
+	summary.TravelTimeInSeconds = int(elapsed)
+	route.Summary = summary
+	response.Routes = []models.Route{route}
+	return response, nil
+}
+
+// getDirectFlightTime returns the time in seconds to fly direct through the stops
+func getDirectFlightTime(stops []restrictedZone.Point, speed float64) float64 {
+	distance := 0.0
+	for i := 1; i < len(stops); i++ {
+		distance += restrictedZone.DistanceMeters(stops[i-1], stops[i])
+	}
+	return distance / restrictedZone.ConvertMphToMps(speed)
+}
+
+// getPlanWaypoints returns the timed points of the plan, a direct path is described by evenly spaced points
+func getPlanWaypoints(plan routePlan, startTime, endTime time.Time) []models.Point {
+	if len(plan.path) == 2 {
+		return GetWaypoints(plan.path[0], plan.path[1], startTime, endTime, directRouteWaypoints)
+	}
+	return GetPathWaypoints(plan.path, startTime, endTime)
+}
+
+// GetPathWaypoints returns the vertices of the path timed at a constant speed between the start and end times
+func GetPathWaypoints(path []restrictedZone.Point, startTime, endTime time.Time) []models.Point {
+	total := 0.0
+	for i := 1; i < len(path); i++ {
+		total += restrictedZone.DistanceMeters(path[i-1], path[i])
+	}
+
+	waypoints := make([]models.Point, 0, len(path))
+	travelled := 0.0
+	for i, p := range path {
+		if i > 0 {
+			travelled += restrictedZone.DistanceMeters(path[i-1], p)
+		}
+		fraction := 0.0
+		if total > 0 {
+			fraction = travelled / total
+		}
+		waypoints = append(waypoints, models.Point{
+			Latitude:  p.Lat,
+			Longitude: p.Lon,
+			Time:      startTime.Add(time.Duration(fraction * float64(endTime.Sub(startTime)))),
+		})
+	}
+	return waypoints
+}
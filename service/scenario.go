@@ -0,0 +1,220 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
+
+	"h3d-drone-emulator/models"
+)
+
+// ErrInvalidScenarioEvent is returned when a timeline event has an unknown action or misses the fields of its action
+var ErrInvalidScenarioEvent = errors.New("invalid scenario event")
+
+// scenarioRunner runs the timeline of the scenario against the simulation clock, in the order of the event times
+type scenarioRunner struct {
+	mutex  sync.Mutex
+	start  time.Time
+	events []models.ScenarioEventStatus
+	stop   chan struct{}
+}
+
+var scenarioRun *scenarioRunner
+
+// newScenarioRunner schedules the events from the start time, events at the same time keep their order
+func newScenarioRunner(start time.Time, events []models.ScenarioEvent) *scenarioRunner {
+	r := &scenarioRunner{start: start, events: make([]models.ScenarioEventStatus, 0, len(events)), stop: make(chan struct{})}
+	for _, event := range events {
+		r.events = append(r.events, models.ScenarioEventStatus{
+			ScenarioEvent: event,
+			Time:          start.Add(time.Duration(event.At * float64(time.Second))),
+			Status:        models.ScenarioEventPending,
+		})
+	}
+	sort.SliceStable(r.events, func(i, j int) bool {
+		return r.events[i].Time.Before(r.events[j].Time)
+	})
+	return r
+}
+
+// run waits for each event on the simulation clock and runs it, until the timeline ends or the runner is closed
+func (r *scenarioRunner) run() {
+	for i := range r.events {
+		r.mutex.Lock()
+		event := r.events[i]
+		r.mutex.Unlock()
+		if wait := event.Time.Sub(simClock.Now()); wait > 0 {
+			select {
+			case <-r.stop:
+				return
+			case <-simClock.After(wait):
+			}
+		}
+		err := runScenarioEvent(event.ScenarioEvent)
+		if errors.Is(err, ErrCommandQueued) {
+			err = nil
+		}
+		r.mutex.Lock()
+		if err != nil {
+			log.Error("Scenario event %s at %gs failed: %s", event.Action, event.At, err.Error())
+			r.events[i].Status = models.ScenarioEventFailed
+			r.events[i].Error = err.Error()
+		} else {
+			log.Info("Scenario event %s at %gs done", event.Action, event.At)
+			r.events[i].Status = models.ScenarioEventDone
+		}
+		r.mutex.Unlock()
+	}
+	log.Info("Scenario timeline completed")
+}
+
+func (r *scenarioRunner) close() {
+	close(r.stop)
+}
+
+func (r *scenarioRunner) status() models.ScenarioStatus {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	events := make([]models.ScenarioEventStatus, len(r.events))
+	copy(events, r.events)
+	return models.ScenarioStatus{StartTime: r.start, Events: events}
+}
+
+// validateScenarioEvent checks the action of an event and the fields it uses
+func validateScenarioEvent(event models.ScenarioEvent) error {
+	if event.At < 0 {
+		return fmt.Errorf("%w: at cannot be negative", ErrInvalidScenarioEvent)
+	}
+	var missing string
+	switch event.Action {
+	case models.ScenarioStartMission:
+		if event.Mission == nil {
+			missing = "mission"
+		} else if event.Mission.ResourceId == nil && event.ResourceId == "" {
+			missing = "resourceId"
+		}
+	case models.ScenarioStopMission, models.ScenarioLoseGps, models.ScenarioRestoreGps:
+		if event.ResourceId == "" {
+			missing = "resourceId"
+		}
+	case models.ScenarioAddZone:
+		if event.Zone == nil {
+			missing = "zone"
+		}
+	case models.ScenarioActivateZone, models.ScenarioDeactivateZone, models.ScenarioRemoveZone:
+		if event.ZoneId == "" {
+			missing = "zoneId"
+		}
+	case models.ScenarioGrantClearance, models.ScenarioDenyClearance:
+		if event.MissionId == "" {
+			missing = "missionId"
+		} else if event.ZoneId == "" {
+			missing = "zoneId"
+		}
+	case models.ScenarioSetBattery:
+		if event.ResourceId == "" {
+			missing = "resourceId"
+		} else if event.BatteryLevel == nil {
+			missing = "batteryLevel"
+		}
+	case models.ScenarioFault:
+		if event.ResourceId == "" {
+			missing = "resourceId"
+		} else if event.Fault == nil {
+			missing = "fault"
+		}
+	default:
+		return fmt.Errorf("%w: unknown action %q", ErrInvalidScenarioEvent, event.Action)
+	}
+	if missing != "" {
+		return fmt.Errorf("%w: %s requires %s", ErrInvalidScenarioEvent, event.Action, missing)
+	}
+	return nil
+}
+
+// runScenarioEvent applies the action of an event through the same functions as the REST API
+func runScenarioEvent(event models.ScenarioEvent) error {
+	if err := validateScenarioEvent(event); err != nil {
+		return err
+	}
+	switch event.Action {
+	case models.ScenarioStartMission:
+		mission := *event.Mission
+		if mission.ResourceId == nil {
+			mission.ResourceId = &event.ResourceId
+		}
+		return StartResourceMission(mission)
+	case models.ScenarioStopMission:
+		return StopResourceMission(models.MissionCommand{ResourceId: &event.ResourceId})
+	case models.ScenarioAddZone:
+		_, err := createZone(*event.Zone, true)
+		return err
+	case models.ScenarioActivateZone:
+		return setZoneActive(event.ZoneId, true, simClock.Now())
+	case models.ScenarioDeactivateZone:
+		return setZoneActive(event.ZoneId, false, simClock.Now())
+	case models.ScenarioRemoveZone:
+		return localZones.delete(event.ZoneId, true)
+	case models.ScenarioGrantClearance:
+		_, err := GrantClearance(event.MissionId, event.ZoneId)
+		return err
+	case models.ScenarioDenyClearance:
+		_, err := DenyClearance(event.MissionId, event.ZoneId)
+		return err
+	case models.ScenarioSetBattery:
+		if _, found := fleet.UpdateDrone(event.ResourceId, func(drone *models.DroneH3D) {
+			drone.BattLevel = math.Max(0, math.Min(100, *event.BatteryLevel))
+		}); !found {
+			return ErrResourceNotFound
+		}
+		return nil
+	case models.ScenarioLoseGps, models.ScenarioRestoreGps:
+		_, err := ApplyFaultCommand(event.ResourceId, models.FaultCommand{Type: models.FaultGpsLoss, Clear: event.Action == models.ScenarioRestoreGps})
+		return err
+	default: // models.ScenarioFault
+		_, err := ApplyFaultCommand(event.ResourceId, *event.Fault)
+		return err
+	}
+}
+
+// setZoneActive activates a local zone from now on without end, or ends its activation now, for the run only
+func setZoneActive(id string, active bool, now time.Time) error {
+	zone, err := localZones.get(id)
+	if err != nil {
+		return err
+	}
+	if active {
+		zone.ActivationStart = &now
+		zone.ActivationEnd = nil
+	} else {
+		zone.ActivationEnd = &now
+		if zone.ActivationStart != nil && !now.After(*zone.ActivationStart) {
+			zone.ActivationStart = nil
+		}
+	}
+	_, err = localZones.put(zone, false, true)
+	return err
+}
+
+// loadScenarioZones creates the zones of the scenario, replacing the local zones of the same id for the run only
+func loadScenarioZones(scenarioZones []models.Zone) {
+	for _, zone := range scenarioZones {
+		_, err := createZone(zone, true)
+		if errors.Is(err, ErrZoneExists) {
+			_, err = localZones.put(zone, false, true)
+		}
+		if err != nil {
+			log.Error("Could not load the scenario zone %s: %s", zone.ID, err.Error())
+		}
+	}
+}
+
+// GetScenarioStatus returns the progress of the timeline of the scenario
+func GetScenarioStatus() models.ScenarioStatus {
+	return scenarioRun.status()
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"h3d-drone-emulator/config"
+	restrictedZone "h3d-drone-emulator/util"
+)
+
+const (
+	SensorModelExact = "exact"
+	SensorModelNoisy = "noisy"
+)
+
+const (
+	// gpsDriftTime is the correlation time of the GPS bias, which wanders around the true position
+	gpsDriftTime = 120 * time.Second
+	// gpsMultipathDuration is the duration of a multipath burst
+	gpsMultipathDuration = 5 * time.Second
+	// temperatureTime is the time constant of the temperature curve, 63% of the rise is reached after it
+	temperatureTime = 10 * time.Minute
+	// cepToSigma converts a circular error probable to the standard deviation of each axis
+	cepToSigma = 1 / 1.1774
+)
+
+// SensorModel turns the true state of a resource into what its sensors report.
+// The reported values of a resource at a given time are the same for its location and its status,
+// so that the values of a telemetry tick must all be measured at the same time.
+type SensorModel interface {
+	// Position returns the reported GPS position
+	Position(resourceId string, position restrictedZone.Point, now time.Time) restrictedZone.Point
+	// Altitude returns the reported barometric altitude, in feet
+	Altitude(resourceId string, altitude float64, now time.Time) float64
+	// Heading returns the reported heading, in degrees
+	Heading(resourceId string, heading int, now time.Time) int
+	// Temperature returns the reported temperature from the ambient one, in degrees Celsius
+	Temperature(resourceId string, temperature int, airborne bool, now time.Time) int
+	String() string
+}
+
+// exactSensorModel reports the true state
+type exactSensorModel struct{}
+
+func (exactSensorModel) Position(_ string, position restrictedZone.Point, _ time.Time) restrictedZone.Point {
+	return position
+}
+
+func (exactSensorModel) Altitude(_ string, altitude float64, _ time.Time) float64 {
+	return altitude
+}
+
+func (exactSensorModel) Heading(_ string, heading int, _ time.Time) int {
+	return heading
+}
+
+func (exactSensorModel) Temperature(_ string, temperature int, _ bool, _ time.Time) int {
+	return temperature
+}
+
+func (exactSensorModel) String() string {
+	return SensorModelExact
+}
+
+// sensorSample is the error of the sensors of a resource at a time. The white noise is drawn once per time,
+// the GPS bias and the multipath bursts evolve with the time elapsed since the previous sample.
+type sensorSample struct {
+	at             time.Time
+	driftEast      float64
+	driftNorth     float64
+	multipathUntil time.Time
+	multipathEast  float64
+	multipathNorth float64
+	noiseEast      float64
+	noiseNorth     float64
+	baro           float64
+	heading        float64
+	warmth         float64
+	warmthAt       time.Time
+}
+
+// noisySensorModel adds the errors of real sensors: GPS noise, bias and multipath bursts, barometric noise,
+// heading jitter and a temperature rising along the flight
+type noisySensorModel struct {
+	mutex           sync.Mutex
+	samples         map[string]*sensorSample
+	gpsSigma        float64
+	gpsDrift        float64
+	multipathRate   float64
+	multipathError  float64
+	baroNoise       float64
+	headingJitter   float64
+	temperatureRise float64
+}
+
+// sampleLocked returns the error of the sensors of a resource at a time, the caller must hold the lock
+func (m *noisySensorModel) sampleLocked(resourceId string, now time.Time) *sensorSample {
+	sample, found := m.samples[resourceId]
+	// Concurrent readers may be slightly behind the latest sample, which they reuse
+	if found && !now.After(sample.at) {
+		return sample
+	}
+	random := resourceStream(resourceId, streamSensors)
+	if !found {
+		sample = &sensorSample{
+			driftEast:  random.NormFloat64() * m.gpsDrift,
+			driftNorth: random.NormFloat64() * m.gpsDrift,
+			warmthAt:   now,
+		}
+		m.samples[resourceId] = sample
+	} else {
+		elapsed := now.Sub(sample.at)
+		// First-order Gauss-Markov bias, exact for any elapsed time
+		decay := math.Exp(-elapsed.Seconds() / gpsDriftTime.Seconds())
+		spread := m.gpsDrift * math.Sqrt(1-decay*decay)
+		sample.driftEast = sample.driftEast*decay + random.NormFloat64()*spread
+		sample.driftNorth = sample.driftNorth*decay + random.NormFloat64()*spread
+		if !now.Before(sample.multipathUntil) && random.Float64() < 1-math.Exp(-m.multipathRate*elapsed.Minutes()) {
+			bearing := random.Float64() * 2 * math.Pi
+			distance := m.multipathError * (0.5 + random.Float64())
+			sample.multipathUntil = now.Add(gpsMultipathDuration)
+			sample.multipathEast = math.Sin(bearing) * distance
+			sample.multipathNorth = math.Cos(bearing) * distance
+		}
+	}
+	sample.at = now
+	sample.noiseEast = random.NormFloat64() * m.gpsSigma
+	sample.noiseNorth = random.NormFloat64() * m.gpsSigma
+	sample.baro = random.NormFloat64() * m.baroNoise
+	sample.heading = random.NormFloat64() * m.headingJitter
+	return sample
+}
+
+func (m *noisySensorModel) Position(resourceId string, position restrictedZone.Point, now time.Time) restrictedZone.Point {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	sample := m.sampleLocked(resourceId, now)
+	east := sample.driftEast + sample.noiseEast
+	north := sample.driftNorth + sample.noiseNorth
+	if now.Before(sample.multipathUntil) {
+		east += sample.multipathEast
+		north += sample.multipathNorth
+	}
+	distance := math.Hypot(east, north)
+	if distance == 0 {
+		return position
+	}
+	bearing := math.Mod(math.Atan2(east, north)*180/math.Pi+360, 360)
+	return restrictedZone.DestinationPoint(position, bearing, distance)
+}
+
+func (m *noisySensorModel) Altitude(resourceId string, altitude float64, now time.Time) float64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	sample := m.sampleLocked(resourceId, now)
+	return math.Max(0, altitude+sample.baro/restrictedZone.FeetToMeters)
+}
+
+func (m *noisySensorModel) Heading(resourceId string, heading int, now time.Time) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	sample := m.sampleLocked(resourceId, now)
+	jittered := int(math.Round(float64(heading) + sample.heading))
+	return (jittered%360 + 360) % 360
+}
+
+// Temperature heats the drone toward the ambient temperature plus the rise while it flies, and cools it down on the ground
+func (m *noisySensorModel) Temperature(resourceId string, temperature int, airborne bool, now time.Time) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	sample := m.sampleLocked(resourceId, now)
+	if elapsed := now.Sub(sample.warmthAt); elapsed > 0 {
+		target := 0.0
+		if airborne {
+			target = m.temperatureRise
+		}
+		sample.warmth = target + (sample.warmth-target)*math.Exp(-elapsed.Seconds()/temperatureTime.Seconds())
+		sample.warmthAt = now
+	}
+	return temperature + int(math.Round(sample.warmth))
+}
+
+func (m *noisySensorModel) String() string {
+	return SensorModelNoisy
+}
+
+var sensors SensorModel = exactSensorModel{}
+
+// NewSensorModel creates the sensor model selected by the sensor-model option
+func NewSensorModel(appConfig config.AppConfig) (SensorModel, error) {
+	switch strings.ToLower(*appConfig.SensorModel) {
+	case SensorModelExact:
+		return exactSensorModel{}, nil
+	case SensorModelNoisy:
+		return &noisySensorModel{
+			samples:         make(map[string]*sensorSample),
+			gpsSigma:        *appConfig.GpsCep * cepToSigma,
+			gpsDrift:        *appConfig.GpsDrift,
+			multipathRate:   *appConfig.GpsMultipathRate,
+			multipathError:  *appConfig.GpsMultipathError,
+			baroNoise:       *appConfig.BaroNoise,
+			headingJitter:   *appConfig.HeadingJitter,
+			temperatureRise: *appConfig.TemperatureRise,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown sensor model %s", *appConfig.SensorModel)
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"h3d-drone-emulator/models"
+)
+
+// ErrInvalidClockCommand is returned when a clock command has invalid parameters
+var ErrInvalidClockCommand = errors.New("invalid clock command")
+
+// Clock is the time source of the simulation
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+}
+
+// SimClock is a virtual clock which can be paused, accelerated or stepped.
+// Simulation time flows at speed times the real time while the clock is running.
+type SimClock struct {
+	mutex    sync.Mutex
+	simBase  time.Time
+	realBase time.Time
+	speed    float64
+	paused   bool
+	timers   []simTimer
+	wake     chan struct{}
+}
+
+// simTimer is a pending After call, fired once the simulation time reaches its deadline
+type simTimer struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// NewSimClock creates a running clock starting at the given time
+func NewSimClock(start time.Time, speed float64) *SimClock {
+	if speed <= 0 {
+		speed = 1
+	}
+	c := &SimClock{
+		simBase:  start,
+		realBase: time.Now(),
+		speed:    speed,
+		wake:     make(chan struct{}, 1),
+	}
+	go c.run()
+	return c
+}
+
+// Now returns the current simulation time
+func (c *SimClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.nowLocked()
+}
+
+func (c *SimClock) nowLocked() time.Time {
+	if c.paused {
+		return c.simBase
+	}
+	elapsed := float64(time.Since(c.realBase)) * c.speed
+	return c.simBase.Add(time.Duration(elapsed))
+}
+
+// rebaseLocked makes the current simulation time the new base of the clock
+func (c *SimClock) rebaseLocked() {
+	c.simBase = c.nowLocked()
+	c.realBase = time.Now()
+}
+
+// After returns a channel receiving the simulation time once the duration has elapsed in simulation time
+func (c *SimClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	timer := simTimer{deadline: c.nowLocked().Add(d), ch: make(chan time.Time, 1)}
+	index := sort.Search(len(c.timers), func(i int) bool {
+		return c.timers[i].deadline.After(timer.deadline)
+	})
+	c.timers = append(c.timers, simTimer{})
+	copy(c.timers[index+1:], c.timers[index:])
+	c.timers[index] = timer
+	c.notify()
+	return timer.ch
+}
+
+// Sleep blocks until the duration has elapsed in simulation time
+func (c *SimClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+// Pause freezes the simulation time
+func (c *SimClock) Pause() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.paused {
+		c.rebaseLocked()
+		c.paused = true
+	}
+	c.notify()
+}
+
+// Resume restarts the simulation time after a pause
+func (c *SimClock) Resume() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.paused {
+		c.realBase = time.Now()
+		c.paused = false
+	}
+	c.notify()
+}
+
+// SetSpeed changes the ratio between simulation time and real time
+func (c *SimClock) SetSpeed(speed float64) error {
+	if speed <= 0 {
+		return fmt.Errorf("%w: speed must be positive", ErrInvalidClockCommand)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.rebaseLocked()
+	c.speed = speed
+	c.notify()
+	return nil
+}
+
+// Step advances the simulation time by the duration at once, firing every timer on the way
+func (c *SimClock) Step(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("%w: step must be positive", ErrInvalidClockCommand)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.rebaseLocked()
+	c.simBase = c.simBase.Add(d)
+	c.notify()
+	return nil
+}
+
+// Status returns the current state of the clock
+func (c *SimClock) Status() models.SimClockStatus {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return models.SimClockStatus{
+		Now:           c.nowLocked(),
+		Speed:         c.speed,
+		Paused:        c.paused,
+		PendingTimers: len(c.timers),
+	}
+}
+
+// notify wakes the timer loop up, the caller must hold the lock
+func (c *SimClock) notify() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run fires the timers whose deadline is reached and sleeps until the next deadline in real time
+func (c *SimClock) run() {
+	for {
+		c.mutex.Lock()
+		now := c.nowLocked()
+		fired := 0
+		for fired < len(c.timers) && !c.timers[fired].deadline.After(now) {
+			c.timers[fired].ch <- now
+			fired++
+		}
+		c.timers = c.timers[fired:]
+
+		var timer *time.Timer
+		var wait <-chan time.Time
+		if !c.paused && len(c.timers) > 0 {
+			timer = time.NewTimer(time.Duration(float64(c.timers[0].deadline.Sub(now)) / c.speed))
+			wait = timer.C
+		}
+		c.mutex.Unlock()
+
+		select {
+		case <-c.wake:
+		case <-wait:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// clockStatus returns the state of the simulation clock along with the seed of the run
+func clockStatus() models.SimClockStatus {
+	status := simClock.Status()
+	status.Seed = simSeed
+	return status
+}
+
+// SimNow returns the current simulation time
+func SimNow() time.Time {
+	return simClock.Now()
+}
+
+// GetClockStatus returns the state of the simulation clock
+func GetClockStatus() models.SimClockStatus {
+	return clockStatus()
+}
+
+// PauseClock freezes the simulation
+func PauseClock() models.SimClockStatus {
+	simClock.Pause()
+	return clockStatus()
+}
+
+// ResumeClock restarts the simulation after a pause
+func ResumeClock() models.SimClockStatus {
+	simClock.Resume()
+	return clockStatus()
+}
+
+// SetClockSpeed runs the simulation at the given multiple of the real time
+func SetClockSpeed(command models.SimClockCommand) (models.SimClockStatus, error) {
+	if command.Speed == nil {
+		return models.SimClockStatus{}, fmt.Errorf("%w: speed is required", ErrInvalidClockCommand)
+	}
+	if err := simClock.SetSpeed(*command.Speed); err != nil {
+		return models.SimClockStatus{}, err
+	}
+	return clockStatus(), nil
+}
+
+// StepClock advances the simulation by the given number of seconds, one simulation tick by default
+func StepClock(command models.SimClockCommand) (models.SimClockStatus, error) {
+	step := *applicationConfig.SimTick
+	if command.Seconds != nil {
+		step = *command.Seconds
+	}
+	if err := simClock.Step(time.Duration(step * float64(time.Second))); err != nil {
+		return models.SimClockStatus{}, err
+	}
+	return clockStatus(), nil
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
+
+	"h3d-drone-emulator/models"
+)
+
+// maxRetryBackoff caps the time waited between two delivery attempts
+const maxRetryBackoff = 30 * time.Second
+
+// deliveryCloseTimeout bounds the time spent flushing a queue on shutdown
+const deliveryCloseTimeout = 5 * time.Second
+
+// ErrTelemetryQueueFull is returned when an event is dropped because the queue of a sink is full
+var ErrTelemetryQueueFull = errors.New("telemetry queue full")
+
+// telemetryEvent is either a location or a drone status waiting for delivery
+type telemetryEvent struct {
+	location *models.ResourceLocation
+	status   *models.DroneStatus
+}
+
+// deliveryOptions tune the delivery of the events to a sink
+type deliveryOptions struct {
+	QueueSize     int
+	MaxRetries    int
+	RetryBackoff  time.Duration
+	BatchSize     int
+	BatchInterval time.Duration
+}
+
+// getDeliveryOptions returns the delivery options set in the configuration
+func getDeliveryOptions() deliveryOptions {
+	return deliveryOptions{
+		QueueSize:     *applicationConfig.TelemetryQueueSize,
+		MaxRetries:    *applicationConfig.TelemetryMaxRetries,
+		RetryBackoff:  time.Duration(*applicationConfig.TelemetryRetryBackoff * float64(time.Second)),
+		BatchSize:     *applicationConfig.TelemetryBatchSize,
+		BatchInterval: time.Duration(*applicationConfig.TelemetryBatchInterval * float64(time.Second)),
+	}
+}
+
+// deliveryQueue is a telemetry sink which queues the events and delivers them to the wrapped sink
+// in the background, so that a slow or unreachable sink never blocks the simulation.
+// Events are dropped once the bounded queue is full.
+type deliveryQueue struct {
+	sink    TelemetrySink
+	options deliveryOptions
+	events  chan telemetryEvent
+	done    chan struct{}
+	mutex   sync.RWMutex
+	closed  bool
+
+	sent    atomic.Int64
+	retried atomic.Int64
+	failed  atomic.Int64
+	dropped atomic.Int64
+}
+
+func newDeliveryQueue(sink TelemetrySink, options deliveryOptions) *deliveryQueue {
+	if options.QueueSize <= 0 {
+		options.QueueSize = 1
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = 1
+	}
+	q := &deliveryQueue{
+		sink:    sink,
+		options: options,
+		events:  make(chan telemetryEvent, options.QueueSize),
+		done:    make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+func (q *deliveryQueue) SendLocation(location models.ResourceLocation) error {
+	return q.enqueue(telemetryEvent{location: &location})
+}
+
+func (q *deliveryQueue) SendDroneStatus(status models.DroneStatus) error {
+	return q.enqueue(telemetryEvent{status: &status})
+}
+
+func (q *deliveryQueue) enqueue(event telemetryEvent) error {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+	if q.closed {
+		q.dropped.Add(1)
+		return ErrTelemetryQueueFull
+	}
+	select {
+	case q.events <- event:
+		return nil
+	default:
+		q.dropped.Add(1)
+		return ErrTelemetryQueueFull
+	}
+}
+
+// Close stops accepting events and flushes the queue, within a timeout
+func (q *deliveryQueue) Close() error {
+	q.mutex.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.events)
+	}
+	q.mutex.Unlock()
+
+	select {
+	case <-q.done:
+	case <-time.After(deliveryCloseTimeout):
+		log.Error("Could not flush %d events to %s", len(q.events), q.sink)
+	}
+	return q.sink.Close()
+}
+
+func (q *deliveryQueue) String() string {
+	return q.sink.String()
+}
+
+// Stats returns the delivery counters of the queue
+func (q *deliveryQueue) Stats() models.TelemetrySinkStats {
+	return models.TelemetrySinkStats{
+		Sink:          q.sink.String(),
+		QueueLength:   len(q.events),
+		QueueCapacity: cap(q.events),
+		Sent:          q.sent.Load(),
+		Retried:       q.retried.Load(),
+		Failed:        q.failed.Load(),
+		Dropped:       q.dropped.Load(),
+	}
+}
+
+// run delivers the queued events, by batches when the sink supports them
+func (q *deliveryQueue) run() {
+	defer close(q.done)
+	batch, batching := q.sink.(batchTelemetrySink)
+	if q.options.BatchSize <= 1 {
+		batching = false
+	}
+	for event := range q.events {
+		if !batching {
+			q.deliver(1, func() error {
+				if event.location != nil {
+					return q.sink.SendLocation(*event.location)
+				}
+				return q.sink.SendDroneStatus(*event.status)
+			})
+			continue
+		}
+
+		events := q.collectBatch(event)
+		locations := make([]models.ResourceLocation, 0, len(events))
+		statuses := make([]models.DroneStatus, 0, len(events))
+		for _, e := range events {
+			if e.location != nil {
+				locations = append(locations, *e.location)
+			} else {
+				statuses = append(statuses, *e.status)
+			}
+		}
+		if len(locations) > 0 {
+			q.deliver(len(locations), func() error { return batch.SendLocations(locations) })
+		}
+		if len(statuses) > 0 {
+			q.deliver(len(statuses), func() error { return batch.SendDroneStatuses(statuses) })
+		}
+	}
+}
+
+// collectBatch gathers the events following the first one, until the batch is full or the batch interval is over
+func (q *deliveryQueue) collectBatch(first telemetryEvent) []telemetryEvent {
+	events := []telemetryEvent{first}
+	timeout := time.NewTimer(q.options.BatchInterval)
+	defer timeout.Stop()
+	for len(events) < q.options.BatchSize {
+		select {
+		case event, ok := <-q.events:
+			if !ok {
+				return events
+			}
+			events = append(events, event)
+		case <-timeout.C:
+			return events
+		}
+	}
+	return events
+}
+
+// deliver sends count events, retrying with an exponential backoff while the error is transient
+func (q *deliveryQueue) deliver(count int, send func() error) {
+	backoff := q.options.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := send()
+		if err == nil {
+			q.sent.Add(int64(count))
+			return
+		}
+		if attempt >= q.options.MaxRetries || !isRetryableTelemetryError(err) {
+			log.Error("Could not deliver %d events to %s: %s", count, q.sink, err.Error())
+			q.failed.Add(int64(count))
+			return
+		}
+		q.retried.Add(int64(count))
+		time.Sleep(backoff)
+		backoff = min(2*backoff, maxRetryBackoff)
+	}
+}
+
+// isRetryableTelemetryError tells whether a delivery may succeed later: connection errors and 5xx responses
+func isRetryableTelemetryError(err error) bool {
+	if errors.Is(err, errTelemetryEncoding) {
+		return false
+	}
+	var statusErr *telemetryStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == 429
+	}
+	return true
+}
+
+// GetTelemetryStats returns the delivery counters of every telemetry sink
+func GetTelemetryStats() []models.TelemetrySinkStats {
+	stats := make([]models.TelemetrySinkStats, 0, len(telemetrySinks))
+	for _, sink := range telemetrySinks {
+		if queue, ok := sink.(*deliveryQueue); ok {
+			stats = append(stats, queue.Stats())
+		}
+	}
+	return stats
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"h3d-drone-emulator/models"
+)
+
+// fakeTelemetrySink fails its first sends with the given errors, and blocks every send while block is open
+type fakeTelemetrySink struct {
+	mutex     sync.Mutex
+	failures  []error
+	calls     int
+	locations []models.ResourceLocation
+	started   chan struct{}
+	block     chan struct{}
+}
+
+func (s *fakeTelemetrySink) SendLocation(location models.ResourceLocation) error {
+	if s.started != nil {
+		s.started <- struct{}{}
+	}
+	if s.block != nil {
+		<-s.block
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.calls++
+	if len(s.failures) > 0 {
+		err := s.failures[0]
+		s.failures = s.failures[1:]
+		return err
+	}
+	s.locations = append(s.locations, location)
+	return nil
+}
+
+func (s *fakeTelemetrySink) SendDroneStatus(status models.DroneStatus) error {
+	return nil
+}
+
+func (s *fakeTelemetrySink) Close() error {
+	return nil
+}
+
+func (s *fakeTelemetrySink) String() string {
+	return "fake"
+}
+
+func TestDeliveryQueueDropsEventsWhenFull(t *testing.T) {
+	sink := &fakeTelemetrySink{started: make(chan struct{}, 10), block: make(chan struct{})}
+	queue := newDeliveryQueue(sink, deliveryOptions{QueueSize: 2, BatchSize: 1})
+
+	// The first event is taken by the delivery loop, which blocks in the sink
+	if err := queue.SendLocation(models.ResourceLocation{ResourceId: "R0"}); err != nil {
+		t.Fatalf("first event: %v", err)
+	}
+	select {
+	case <-sink.started:
+	case <-time.After(time.Second):
+		t.Fatal("the first event was not delivered")
+	}
+	// Two events fill the queue, the next three are dropped
+	for i := 1; i <= 5; i++ {
+		err := queue.SendLocation(models.ResourceLocation{ResourceId: "R"})
+		if wantDropped := i > 2; errors.Is(err, ErrTelemetryQueueFull) != wantDropped {
+			t.Errorf("event %d: error = %v, dropped want %t", i, err, wantDropped)
+		}
+	}
+	close(sink.block)
+	queue.Close()
+
+	stats := queue.Stats()
+	if stats.Dropped != 3 || stats.Sent != 3 {
+		t.Errorf("dropped = %d, sent = %d, want 3 and 3", stats.Dropped, stats.Sent)
+	}
+}
+
+func TestDeliveryQueueRetries(t *testing.T) {
+	unavailable := &telemetryStatusError{Url: "fake", StatusCode: 503}
+	badRequest := &telemetryStatusError{Url: "fake", StatusCode: 400}
+	tests := []struct {
+		name        string
+		failures    []error
+		maxRetries  int
+		wantCalls   int
+		wantSent    int64
+		wantRetried int64
+		wantFailed  int64
+	}{
+		{name: "success", wantCalls: 1, wantSent: 1},
+		{name: "retry then success", failures: []error{unavailable, unavailable}, maxRetries: 3, wantCalls: 3, wantSent: 1, wantRetried: 2},
+		{name: "retries exhausted", failures: []error{unavailable, unavailable, unavailable}, maxRetries: 2, wantCalls: 3, wantRetried: 2, wantFailed: 1},
+		{name: "not retryable", failures: []error{badRequest}, maxRetries: 3, wantCalls: 1, wantFailed: 1},
+		{name: "encoding error", failures: []error{errTelemetryEncoding}, maxRetries: 3, wantCalls: 1, wantFailed: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &fakeTelemetrySink{failures: tt.failures}
+			queue := newDeliveryQueue(sink, deliveryOptions{QueueSize: 10, MaxRetries: tt.maxRetries, RetryBackoff: time.Millisecond, BatchSize: 1})
+			if err := queue.SendLocation(models.ResourceLocation{ResourceId: "R1"}); err != nil {
+				t.Fatalf("send: %v", err)
+			}
+			queue.Close()
+
+			stats := queue.Stats()
+			if sink.calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", sink.calls, tt.wantCalls)
+			}
+			if stats.Sent != tt.wantSent || stats.Retried != tt.wantRetried || stats.Failed != tt.wantFailed {
+				t.Errorf("sent = %d, retried = %d, failed = %d, want %d, %d, %d",
+					stats.Sent, stats.Retried, stats.Failed, tt.wantSent, tt.wantRetried, tt.wantFailed)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"h3d-drone-emulator/config"
+	"h3d-drone-emulator/models"
+)
+
+const (
+	TelemetrySinkRest  = "rest"
+	TelemetrySinkKafka = "kafka"
+)
+
+// kafkaWriteTimeout bounds the time spent publishing one message to the brokers
+const kafkaWriteTimeout = 10 * time.Second
+
+// errTelemetryEncoding is returned when an event cannot be encoded, such events are never retried
+var errTelemetryEncoding = errors.New("could not encode telemetry")
+
+// telemetryStatusError is returned when the receiver of the telemetry answers with an error status
+type telemetryStatusError struct {
+	Url        string
+	StatusCode int
+}
+
+func (e *telemetryStatusError) Error() string {
+	return fmt.Sprintf("%s responded with status %d", e.Url, e.StatusCode)
+}
+
+// TelemetrySink publishes the locations and the drone statuses of the fleet
+type TelemetrySink interface {
+	SendLocation(location models.ResourceLocation) error
+	SendDroneStatus(status models.DroneStatus) error
+	Close() error
+	String() string
+}
+
+// batchTelemetrySink is a sink able to publish several events at once
+type batchTelemetrySink interface {
+	SendLocations(locations []models.ResourceLocation) error
+	SendDroneStatuses(statuses []models.DroneStatus) error
+}
+
+// restSink posts the telemetry to the drone connector
+type restSink struct {
+	client            *http.Client
+	baseUrl           string
+	batchLocationPath string
+	batchStatusPath   string
+}
+
+func (s *restSink) SendLocation(location models.ResourceLocation) error {
+	return s.post(s.baseUrl+"/"+location.ResourceId+"/location", location)
+}
+
+func (s *restSink) SendDroneStatus(status models.DroneStatus) error {
+	return s.post(s.baseUrl+"/"+status.ResourceId+"/status", status)
+}
+
+func (s *restSink) SendLocations(locations []models.ResourceLocation) error {
+	return s.post(s.baseUrl+s.batchLocationPath, locations)
+}
+
+func (s *restSink) SendDroneStatuses(statuses []models.DroneStatus) error {
+	return s.post(s.baseUrl+s.batchStatusPath, statuses)
+}
+
+func (s *restSink) post(postUrl string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errTelemetryEncoding, err)
+	}
+	resp, err := s.client.Post(postUrl, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &telemetryStatusError{Url: postUrl, StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+func (s *restSink) Close() error {
+	return nil
+}
+
+func (s *restSink) String() string {
+	return "rest://" + s.baseUrl
+}
+
+// kafkaSink publishes the telemetry to Kafka topics, keyed by resource ID so that
+// the messages of a resource stay ordered on the same partition
+type kafkaSink struct {
+	brokers   string
+	locations *kafka.Writer
+	statuses  *kafka.Writer
+}
+
+func newKafkaWriter(brokers []string, topic string) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:                   kafka.TCP(brokers...),
+		Topic:                  topic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireOne,
+		BatchTimeout:           10 * time.Millisecond,
+		AllowAutoTopicCreation: true,
+	}
+}
+
+func (s *kafkaSink) SendLocation(location models.ResourceLocation) error {
+	return s.publish(s.locations, location.ResourceId, location)
+}
+
+func (s *kafkaSink) SendDroneStatus(status models.DroneStatus) error {
+	return s.publish(s.statuses, status.ResourceId, status)
+}
+
+func (s *kafkaSink) SendLocations(locations []models.ResourceLocation) error {
+	messages := make([]kafka.Message, 0, len(locations))
+	for _, location := range locations {
+		message, err := newKafkaMessage(location.ResourceId, location)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, message)
+	}
+	return s.write(s.locations, messages...)
+}
+
+func (s *kafkaSink) SendDroneStatuses(statuses []models.DroneStatus) error {
+	messages := make([]kafka.Message, 0, len(statuses))
+	for _, status := range statuses {
+		message, err := newKafkaMessage(status.ResourceId, status)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, message)
+	}
+	return s.write(s.statuses, messages...)
+}
+
+func (s *kafkaSink) publish(writer *kafka.Writer, resourceId string, v interface{}) error {
+	message, err := newKafkaMessage(resourceId, v)
+	if err != nil {
+		return err
+	}
+	return s.write(writer, message)
+}
+
+func (s *kafkaSink) write(writer *kafka.Writer, messages ...kafka.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaWriteTimeout)
+	defer cancel()
+	return writer.WriteMessages(ctx, messages...)
+}
+
+func newKafkaMessage(resourceId string, v interface{}) (kafka.Message, error) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return kafka.Message{}, fmt.Errorf("%w: %s", errTelemetryEncoding, err)
+	}
+	return kafka.Message{Key: []byte(resourceId), Value: value}, nil
+}
+
+func (s *kafkaSink) Close() error {
+	locationErr := s.locations.Close()
+	if err := s.statuses.Close(); err != nil {
+		return err
+	}
+	return locationErr
+}
+
+func (s *kafkaSink) String() string {
+	return "kafka://" + s.brokers
+}
+
+// NewTelemetrySinks creates the sinks selected by the telemetry-sinks option
+func NewTelemetrySinks(appConfig config.AppConfig, client *http.Client) ([]TelemetrySink, error) {
+	sinks := make([]TelemetrySink, 0)
+	for _, name := range strings.Split(*appConfig.TelemetrySinks, ",") {
+		switch strings.TrimSpace(name) {
+		case TelemetrySinkRest:
+			sinks = append(sinks, &restSink{
+				client:            client,
+				baseUrl:           *appConfig.RestAPIAddress + *appConfig.ResourcesBasePath,
+				batchLocationPath: *appConfig.RestBatchLocationPath,
+				batchStatusPath:   *appConfig.RestBatchStatusPath,
+			})
+		case TelemetrySinkKafka:
+			brokers := strings.Split(*appConfig.BrokerAddress, ",")
+			sinks = append(sinks, &kafkaSink{
+				brokers:   *appConfig.BrokerAddress,
+				locations: newKafkaWriter(brokers, *appConfig.KafkaLocationTopic),
+				statuses:  newKafkaWriter(brokers, *appConfig.Topic),
+			})
+		default:
+			return nil, fmt.Errorf("unknown telemetry sink: %s", name)
+		}
+	}
+	return sinks, nil
+}
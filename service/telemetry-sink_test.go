@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+	"github.com/segmentio/kafka-go/protocol/metadata"
+	"github.com/segmentio/kafka-go/protocol/produce"
+
+	"h3d-drone-emulator/config"
+	"h3d-drone-emulator/models"
+)
+
+// fakeBrokerRecord is a record produced to the fake broker
+type fakeBrokerRecord struct {
+	topic     string
+	partition int32
+	key       string
+	value     []byte
+}
+
+// fakeBroker is an in-process Kafka broker, plugged as the transport of the real writers. It serves the metadata
+// of any topic with its partitions, records what is produced and answers each produce with errorCode.
+type fakeBroker struct {
+	mutex      sync.Mutex
+	partitions int
+	errorCode  kafka.Error
+	records    []fakeBrokerRecord
+}
+
+func (b *fakeBroker) RoundTrip(_ context.Context, _ net.Addr, request kafka.Request) (kafka.Response, error) {
+	switch request := request.(type) {
+	case *metadata.Request:
+		response := &metadata.Response{Brokers: []metadata.ResponseBroker{{NodeID: 1, Host: "localhost", Port: 9092}}}
+		for _, name := range request.TopicNames {
+			topic := metadata.ResponseTopic{Name: name}
+			for i := 0; i < b.partitions; i++ {
+				topic.Partitions = append(topic.Partitions, metadata.ResponsePartition{PartitionIndex: int32(i), LeaderID: 1})
+			}
+			response.Topics = append(response.Topics, topic)
+		}
+		return response, nil
+	case *produce.Request:
+		response := &produce.Response{}
+		for _, topic := range request.Topics {
+			responseTopic := produce.ResponseTopic{Topic: topic.Topic}
+			for _, partition := range topic.Partitions {
+				if err := b.store(topic.Topic, partition); err != nil {
+					return nil, err
+				}
+				responseTopic.Partitions = append(responseTopic.Partitions, produce.ResponsePartition{Partition: partition.Partition, ErrorCode: int16(b.errorCode)})
+			}
+			response.Topics = append(response.Topics, responseTopic)
+		}
+		return response, nil
+	}
+	return nil, errors.New("unexpected request")
+}
+
+func (b *fakeBroker) store(topic string, partition produce.RequestPartition) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for {
+		record, err := partition.RecordSet.Records.ReadRecord()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		key, err := protocol.ReadAll(record.Key)
+		if err != nil {
+			return err
+		}
+		value, err := protocol.ReadAll(record.Value)
+		if err != nil {
+			return err
+		}
+		b.records = append(b.records, fakeBrokerRecord{topic: topic, partition: partition.Partition, key: string(key), value: value})
+	}
+}
+
+// newFakeKafkaSink creates the Kafka sink of the telemetry-sinks option, producing to a fake broker
+func newFakeKafkaSink(t *testing.T) (*kafkaSink, *fakeBroker) {
+	sinkNames, brokers := TelemetrySinkKafka, "localhost:9092"
+	locationTopic, statusTopic := "RmsResourceLocation", "dronestats"
+	sinks, err := NewTelemetrySinks(config.AppConfig{
+		TelemetrySinks:     &sinkNames,
+		BrokerAddress:      &brokers,
+		KafkaLocationTopic: &locationTopic,
+		Topic:              &statusTopic,
+	}, nil)
+	if err != nil {
+		t.Fatalf("create sinks: %v", err)
+	}
+	sink, ok := sinks[0].(*kafkaSink)
+	if len(sinks) != 1 || !ok {
+		t.Fatalf("sinks = %v, want a single Kafka sink", sinks)
+	}
+	broker := &fakeBroker{partitions: 3}
+	sink.locations.Transport = broker
+	sink.statuses.Transport = broker
+	t.Cleanup(func() { sink.Close() })
+	return sink, broker
+}
+
+func TestKafkaSinkRoutesTelemetryToTopics(t *testing.T) {
+	location := models.ResourceLocation{ResourceId: "R1", Location: "1.3,103.8", Altitude: 120, IsExternal: true, TimestampMs: 1000}
+	status := models.DroneStatus{ResourceId: "R2", Speed: 12, BatteryLevel: 80, GpsStatus: "6", TimestampMs: 2000}
+
+	tests := []struct {
+		name       string
+		send       func(sink *kafkaSink) error
+		topic      string
+		resourceId string
+		decode     func(value []byte) (interface{}, error)
+		want       interface{}
+	}{
+		{
+			name:       "location",
+			send:       func(sink *kafkaSink) error { return sink.SendLocation(location) },
+			topic:      "RmsResourceLocation",
+			resourceId: "R1",
+			decode: func(value []byte) (interface{}, error) {
+				var decoded models.ResourceLocation
+				err := json.Unmarshal(value, &decoded)
+				return decoded, err
+			},
+			want: location,
+		},
+		{
+			name:       "status",
+			send:       func(sink *kafkaSink) error { return sink.SendDroneStatus(status) },
+			topic:      "dronestats",
+			resourceId: "R2",
+			decode: func(value []byte) (interface{}, error) {
+				var decoded models.DroneStatus
+				err := json.Unmarshal(value, &decoded)
+				return decoded, err
+			},
+			want: status,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink, broker := newFakeKafkaSink(t)
+			if err := tt.send(sink); err != nil {
+				t.Fatalf("send: %v", err)
+			}
+			if len(broker.records) != 1 {
+				t.Fatalf("got %d records, want 1", len(broker.records))
+			}
+			record := broker.records[0]
+			if record.topic != tt.topic {
+				t.Errorf("topic = %s, want %s", record.topic, tt.topic)
+			}
+			if record.key != tt.resourceId {
+				t.Errorf("key = %q, want %q", record.key, tt.resourceId)
+			}
+			decoded, err := tt.decode(record.value)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !reflect.DeepEqual(decoded, tt.want) {
+				t.Errorf("value = %+v, want %+v", decoded, tt.want)
+			}
+		})
+	}
+}
+
+func TestKafkaSinkKeepsResourcesOnTheirPartition(t *testing.T) {
+	sink, broker := newFakeKafkaSink(t)
+	for i := 0; i < 3; i++ {
+		for _, resourceId := range []string{"R1", "R2", "R3", "R4"} {
+			if err := sink.SendLocation(models.ResourceLocation{ResourceId: resourceId}); err != nil {
+				t.Fatalf("send: %v", err)
+			}
+		}
+	}
+	if len(broker.records) != 12 {
+		t.Fatalf("got %d records, want 12", len(broker.records))
+	}
+	partitions := make(map[string]int32)
+	for _, record := range broker.records {
+		if partition, found := partitions[record.key]; found && partition != record.partition {
+			t.Errorf("records of %s on partitions %d and %d", record.key, partition, record.partition)
+		}
+		partitions[record.key] = record.partition
+	}
+}
+
+func TestKafkaSinkReturnsBrokerErrors(t *testing.T) {
+	sink, broker := newFakeKafkaSink(t)
+	broker.errorCode = kafka.MessageSizeTooLarge
+	err := sink.SendLocation(models.ResourceLocation{ResourceId: "R1"})
+	var writeErrors kafka.WriteErrors
+	if !errors.As(err, &writeErrors) || len(writeErrors) != 1 || !errors.Is(writeErrors[0], kafka.MessageSizeTooLarge) {
+		t.Errorf("SendLocation error = %v, want %v", err, kafka.MessageSizeTooLarge)
+	}
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
+
+	"h3d-drone-emulator/api"
+	"h3d-drone-emulator/models"
+	restrictedZone "h3d-drone-emulator/util"
+)
+
+// zoneFetchTimeout bounds each request to the custom entity service, lowered to the refresh interval if shorter
+const zoneFetchTimeout = 30 * time.Second
+
+// zoneSnapshot is a set of no-fly zones as fetched by one refresh of the registry
+type zoneSnapshot struct {
+	version   int64
+	zones     []restrictedZone.RestrictedZone
+	updatedAt time.Time
+	stale     bool
+}
+
+// zoneRegistry caches the no-fly zones of the custom entity service and refreshes them periodically.
+// When the service is down, the last good set of zones is kept and reported as stale.
+// The remote zones are merged with the local ones, the version changes with either.
+type zoneRegistry struct {
+	mutex         sync.RWMutex
+	url           string
+	pageSize      int
+	interval      time.Duration
+	version       int64
+	remote        []restrictedZone.RestrictedZone
+	local         []restrictedZone.RestrictedZone
+	zones         []restrictedZone.RestrictedZone
+	loaded        bool
+	updatedAt     time.Time
+	lastAttemptAt time.Time
+	lastError     error
+	stop          chan struct{}
+}
+
+var zones *zoneRegistry
+
+func newZoneRegistry(url string, pageSize int, interval time.Duration) *zoneRegistry {
+	if pageSize <= 0 {
+		pageSize = 1000
+	}
+	return &zoneRegistry{
+		url:      url,
+		pageSize: pageSize,
+		interval: interval,
+		remote:   make([]restrictedZone.RestrictedZone, 0),
+		local:    make([]restrictedZone.RestrictedZone, 0),
+		zones:    make([]restrictedZone.RestrictedZone, 0),
+		stop:     make(chan struct{}),
+	}
+}
+
+// start refreshes the zones now then on every interval, until the registry is closed.
+// Without url, only the local zones are used.
+func (r *zoneRegistry) start() {
+	if r.url == "" {
+		log.Info("No no-fly zones url, using the local zones only")
+		return
+	}
+	go func() {
+		r.refresh()
+		if r.interval <= 0 {
+			return
+		}
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.refresh()
+			}
+		}
+	}()
+}
+
+func (r *zoneRegistry) close() {
+	close(r.stop)
+}
+
+// refresh fetches every remote zone and replaces the cached set, the version is incremented when the zones have changed
+func (r *zoneRegistry) refresh() {
+	attemptAt := time.Now()
+	r.mutex.Lock()
+	r.lastAttemptAt = attemptAt
+	r.mutex.Unlock()
+
+	timeout := zoneFetchTimeout
+	if r.interval > 0 && r.interval < timeout {
+		timeout = r.interval
+	}
+	fetched, err := fetchRestrictedZones(r.url, r.pageSize, timeout)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.lastError = err
+	if err != nil {
+		log.Error("Could not refresh the no-fly zones, keeping the %d zones of version %d: %s", len(r.remote), r.version, err.Error())
+		return
+	}
+	r.updatedAt = attemptAt
+	if !r.loaded || !reflect.DeepEqual(fetched, r.remote) {
+		r.remote = fetched
+		r.mergeLocked()
+		log.Info("Loaded %d no-fly zones, version %d", len(fetched), r.version)
+	}
+	r.loaded = true
+}
+
+// setLocalZones replaces the local zones
+func (r *zoneRegistry) setLocalZones(local []restrictedZone.RestrictedZone) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.local = local
+	r.mergeLocked()
+}
+
+// hasRemote tells whether a remote zone has the id
+func (r *zoneRegistry) hasRemote(id string) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, zone := range r.remote {
+		if zone.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeLocked rebuilds the zones from the remote and the local ones as a new version, the caller must hold the lock
+func (r *zoneRegistry) mergeLocked() {
+	merged := make([]restrictedZone.RestrictedZone, 0, len(r.remote)+len(r.local))
+	merged = append(merged, r.remote...)
+	r.zones = append(merged, r.local...)
+	r.version++
+}
+
+// staleLocked tells whether the remote zones could not be loaded or refreshed, the caller must hold the lock
+func (r *zoneRegistry) staleLocked() bool {
+	return r.url != "" && (!r.loaded || r.lastError != nil)
+}
+
+// snapshot returns the current zones, they must not be modified
+func (r *zoneRegistry) snapshot() zoneSnapshot {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return zoneSnapshot{
+		version:   r.version,
+		zones:     r.zones,
+		updatedAt: r.updatedAt,
+		stale:     r.staleLocked(),
+	}
+}
+
+func (r *zoneRegistry) status() models.ZoneRegistryStatus {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	status := models.ZoneRegistryStatus{
+		Version:                r.version,
+		ZoneCount:              len(r.zones),
+		LocalZoneCount:         len(r.local),
+		Stale:                  r.staleLocked(),
+		RefreshIntervalSeconds: r.interval.Seconds(),
+	}
+	if !r.updatedAt.IsZero() {
+		updatedAt := r.updatedAt
+		status.UpdatedAt = &updatedAt
+		status.AgeSeconds = time.Since(updatedAt).Seconds()
+	}
+	if !r.lastAttemptAt.IsZero() {
+		lastAttemptAt := r.lastAttemptAt
+		status.LastAttemptAt = &lastAttemptAt
+	}
+	if r.lastError != nil {
+		status.LastError = r.lastError.Error()
+	}
+	return status
+}
+
+// fetchRestrictedZones pages through the no-fly zones of the custom entity service, each request failing after the timeout.
+// Zones with an unsupported geometry are skipped.
+func fetchRestrictedZones(url string, pageSize int, timeout time.Duration) ([]restrictedZone.RestrictedZone, error) {
+	client := api.NewClient(timeout)
+	restrictedZones := make([]restrictedZone.RestrictedZone, 0)
+	for offset := 0; ; {
+		requestBody := models.RequestBody{
+			CeInstance: models.CeInstance{
+				TemplateID: models.TemplateID{
+					Equals: []string{"no_fly_zone"},
+				}},
+			Offset:    offset,
+			Limit:     pageSize,
+			Order:     "creation_date:asc",
+			WithTotal: true,
+		}
+		response, err := client.Post(url, requestBody)
+		if err != nil {
+			return nil, fmt.Errorf("could not get zones from offset %d: %w", offset, err)
+		}
+		var result models.ApiResponse
+		if err := json.Unmarshal(response, &result); err != nil {
+			return nil, fmt.Errorf("could not read zones from offset %d: %w", offset, err)
+		}
+
+		for _, instance := range result.CeInstances {
+			polygons, circles, err := restrictedZone.ParseGeometry(instance.Geometry.Type, instance.Geometry.Coordinates, instance.Data.Radius)
+			if err != nil {
+				log.Error("Skipping no-fly zone %s: %s", instance.ID, err.Error())
+				continue
+			}
+			restrictedZones = append(restrictedZones, restrictedZone.RestrictedZone{
+				ID:        instance.ID,
+				Polygons:  polygons,
+				Circles:   circles,
+				StartTime: instance.Data.ActivationStart.TimestampMs,
+				EndTime:   instance.Data.ActivationEnd.TimestampMs,
+				Policy:    instance.Data.Policy,
+			})
+		}
+
+		offset += len(result.CeInstances)
+		if len(result.CeInstances) == 0 || offset >= result.Total {
+			return restrictedZones, nil
+		}
+	}
+}
+
+// GetZoneRegistryStatus returns the version and the staleness of the cached no-fly zones
+func GetZoneRegistryStatus() models.ZoneRegistryStatus {
+	return zones.status()
+}
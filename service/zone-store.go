@@ -0,0 +1,273 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"gitlab.thalesdigital.io/prs-sdp/shared/libs/golang/sdp-common-backend.git/log"
+
+	"h3d-drone-emulator/models"
+	restrictedZone "h3d-drone-emulator/util"
+)
+
+var (
+	// ErrInvalidZone is returned when a zone has an invalid geometry or activation range
+	ErrInvalidZone = errors.New("invalid zone")
+	// ErrZoneNotFound is returned when a local zone does not exist
+	ErrZoneNotFound = errors.New("zone not found")
+	// ErrZoneExists is returned when creating a local zone with the id of another one
+	ErrZoneExists = errors.New("zone already exists")
+)
+
+// zoneStore holds the no-fly zones created through the API, optionally persisted to a JSON file.
+// Every change is pushed to the zone registry, which merges them with the remote zones.
+// The changes of the scenario are kept in memory only: its zones are not saved, and the saved version
+// of a zone changed or removed by the scenario is kept in shadowed so that the file stays as it was.
+type zoneStore struct {
+	mutex    sync.Mutex
+	file     string
+	zones    map[string]models.Zone
+	scenario map[string]bool
+	shadowed map[string]models.Zone
+}
+
+var localZones *zoneStore
+
+// newZoneStore creates the store and loads the zones of the file, if any
+func newZoneStore(file string) *zoneStore {
+	s := &zoneStore{file: file, zones: make(map[string]models.Zone), scenario: make(map[string]bool), shadowed: make(map[string]models.Zone)}
+	if file == "" {
+		return s
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error("Could not read the zones file %s: %s", file, err.Error())
+		}
+		return s
+	}
+	var saved []models.Zone
+	if err := json.Unmarshal(data, &saved); err != nil {
+		log.Error("Could not read the zones file %s: %s", file, err.Error())
+		return s
+	}
+	for _, zone := range saved {
+		if _, err := toRestrictedZone(zone); err != nil {
+			log.Error("Skipping local zone %s: %s", zone.ID, err.Error())
+			continue
+		}
+		s.zones[zone.ID] = zone
+	}
+	log.Info("Loaded %d local no-fly zones from %s", len(s.zones), file)
+	return s
+}
+
+// list returns the zones sorted by id
+func (s *zoneStore) list() []models.Zone {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.listLocked()
+}
+
+func (s *zoneStore) listLocked() []models.Zone {
+	list := make([]models.Zone, 0, len(s.zones))
+	for _, zone := range s.zones {
+		list = append(list, zone)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+func (s *zoneStore) get(id string) (models.Zone, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	zone, found := s.zones[id]
+	if !found {
+		return models.Zone{}, ErrZoneNotFound
+	}
+	return zone, nil
+}
+
+// put creates or replaces a zone, creation fails if the zone already exists.
+// A change of the scenario is not saved, a change through the API is.
+func (s *zoneStore) put(zone models.Zone, create bool, scenario bool) (models.Zone, error) {
+	if _, err := toRestrictedZone(zone); err != nil {
+		return models.Zone{}, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	previous, found := s.zones[zone.ID]
+	if create && found {
+		return models.Zone{}, fmt.Errorf("%w: %s", ErrZoneExists, zone.ID)
+	}
+	if !create && !found {
+		return models.Zone{}, ErrZoneNotFound
+	}
+	if scenario {
+		if found && !s.scenario[zone.ID] {
+			s.shadowed[zone.ID] = previous
+		}
+		s.scenario[zone.ID] = true
+	} else {
+		delete(s.scenario, zone.ID)
+		delete(s.shadowed, zone.ID)
+	}
+	s.zones[zone.ID] = zone
+	s.changedLocked()
+	return zone, nil
+}
+
+// delete removes a zone. A removal by the scenario is not saved, a removal through the API is.
+func (s *zoneStore) delete(id string, scenario bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	zone, found := s.zones[id]
+	if !found {
+		return ErrZoneNotFound
+	}
+	if !scenario {
+		delete(s.shadowed, id)
+	} else if !s.scenario[id] {
+		s.shadowed[id] = zone
+	}
+	delete(s.scenario, id)
+	delete(s.zones, id)
+	s.changedLocked()
+	return nil
+}
+
+// restrictedZones converts the zones for routing and flight checks
+func (s *zoneStore) restrictedZones() []restrictedZone.RestrictedZone {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.restrictedZonesLocked()
+}
+
+func (s *zoneStore) restrictedZonesLocked() []restrictedZone.RestrictedZone {
+	converted := make([]restrictedZone.RestrictedZone, 0, len(s.zones))
+	for _, zone := range s.listLocked() {
+		rz, _ := toRestrictedZone(zone)
+		converted = append(converted, rz)
+	}
+	return converted
+}
+
+// changedLocked saves the zones and updates the registry, the caller must hold the lock
+func (s *zoneStore) changedLocked() {
+	zones.setLocalZones(s.restrictedZonesLocked())
+	if s.file == "" {
+		return
+	}
+	if err := s.saveLocked(); err != nil {
+		log.Error("Could not save the zones to %s: %s", s.file, err.Error())
+	}
+}
+
+// saveLocked writes the zones to a temporary file then renames it, so that the file is never left half written
+func (s *zoneStore) saveLocked() error {
+	saved := make([]models.Zone, 0, len(s.zones))
+	for _, zone := range s.listLocked() {
+		if !s.scenario[zone.ID] {
+			saved = append(saved, zone)
+		}
+	}
+	for _, zone := range s.shadowed {
+		saved = append(saved, zone)
+	}
+	sort.Slice(saved, func(i, j int) bool {
+		return saved[i].ID < saved[j].ID
+	})
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.file), filepath.Base(s.file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.file)
+}
+
+// toRestrictedZone validates the geometry and the activation range of a zone
+func toRestrictedZone(zone models.Zone) (restrictedZone.RestrictedZone, error) {
+	polygons, circles, err := restrictedZone.ParseGeometry(zone.Geometry.Type, zone.Geometry.Coordinates, zone.Radius)
+	if err != nil {
+		return restrictedZone.RestrictedZone{}, fmt.Errorf("%w: %s", ErrInvalidZone, err.Error())
+	}
+	if !isGeofencePolicy(zone.Policy) {
+		return restrictedZone.RestrictedZone{}, fmt.Errorf("%w: policy must be one of HOLD, REROUTE or CONTINUE", ErrInvalidZone)
+	}
+	rz := restrictedZone.RestrictedZone{ID: zone.ID, Polygons: polygons, Circles: circles, Policy: zone.Policy}
+	if zone.ActivationStart != nil {
+		rz.StartTime = zone.ActivationStart.UnixMilli()
+	}
+	if zone.ActivationEnd != nil {
+		rz.EndTime = zone.ActivationEnd.UnixMilli()
+	}
+	if zone.ActivationStart != nil && zone.ActivationEnd != nil && !zone.ActivationEnd.After(*zone.ActivationStart) {
+		return restrictedZone.RestrictedZone{}, fmt.Errorf("%w: activationEnd must be after activationStart", ErrInvalidZone)
+	}
+	return rz, nil
+}
+
+// newObjectId returns a random id in the format of the platform ids
+func newObjectId() string {
+	id := make([]byte, 12)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// ListZones returns the local no-fly zones
+func ListZones() []models.Zone {
+	return localZones.list()
+}
+
+// GetZone returns a local no-fly zone
+func GetZone(id string) (models.Zone, error) {
+	return localZones.get(id)
+}
+
+// CreateZone adds a local no-fly zone, with a generated id if none is given.
+// The id cannot be the one of a remote zone, which would make the zones of that id ambiguous.
+func CreateZone(zone models.Zone) (models.Zone, error) {
+	return createZone(zone, false)
+}
+
+// createZone adds a local no-fly zone through the API or for the scenario
+func createZone(zone models.Zone, scenario bool) (models.Zone, error) {
+	if zone.ID == "" {
+		zone.ID = newObjectId()
+	}
+	if zones.hasRemote(zone.ID) {
+		return models.Zone{}, fmt.Errorf("%w: id %s is the id of a remote zone", ErrInvalidZone, zone.ID)
+	}
+	return localZones.put(zone, true, scenario)
+}
+
+// UpdateZone replaces a local no-fly zone
+func UpdateZone(id string, zone models.Zone) (models.Zone, error) {
+	zone.ID = id
+	return localZones.put(zone, false, false)
+}
+
+// DeleteZone removes a local no-fly zone
+func DeleteZone(id string) error {
+	return localZones.delete(id, false)
+}
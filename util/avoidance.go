@@ -0,0 +1,181 @@
+package util
+
+import "math"
+
+// minAvoidanceBuffer is the smallest distance in meters kept between a detour and a zone
+const minAvoidanceBuffer = 1.0
+
+// PlanAvoidanceRoute returns the shortest path from source to destination staying out of the zones,
+// passing at the buffer distance in meters around their corners. The path is searched on a visibility graph
+// whose nodes are the source, the destination and the buffered convex corners of the zones.
+// Areas containing the source or the destination cannot be avoided and are ignored.
+// It returns false if no path goes around the zones.
+func PlanAvoidanceRoute(source, destination Point, zones []RestrictedZone, buffer float64) ([]Point, bool) {
+	buffer = math.Max(buffer, minAvoidanceBuffer)
+	projection := newLocalProjection(source)
+	start := projection.toVec(source)
+	end := projection.toVec(destination)
+
+	obstacles := make([][]vec, 0, len(zones))
+	for _, zone := range zones {
+		// Holes do not help going around an area
+		for _, area := range zone.Areas() {
+			ring := projectRing(projection, area.Outer)
+			if len(ring) < 3 || isVecInRing(start, ring) || isVecInRing(end, ring) {
+				continue
+			}
+			obstacles = append(obstacles, ring)
+		}
+	}
+
+	nodes := []vec{start, end}
+	for _, ring := range obstacles {
+		for _, corner := range bufferedCorners(ring, buffer) {
+			if !isVecInObstacles(corner, obstacles) {
+				nodes = append(nodes, corner)
+			}
+		}
+	}
+
+	// Dijkstra on the visibility graph, the edges are found while searching
+	distances := make([]float64, len(nodes))
+	previous := make([]int, len(nodes))
+	visited := make([]bool, len(nodes))
+	for i := range nodes {
+		distances[i] = math.Inf(1)
+		previous[i] = -1
+	}
+	distances[0] = 0
+	for {
+		current := -1
+		for i := range nodes {
+			if !visited[i] && !math.IsInf(distances[i], 1) && (current < 0 || distances[i] < distances[current]) {
+				current = i
+			}
+		}
+		if current < 0 {
+			return nil, false
+		}
+		if current == 1 {
+			break
+		}
+		visited[current] = true
+		for next := range nodes {
+			if visited[next] {
+				continue
+			}
+			distance := distances[current] + nodes[current].distance(nodes[next])
+			if distance < distances[next] && isVisible(nodes[current], nodes[next], obstacles) {
+				distances[next] = distance
+				previous[next] = current
+			}
+		}
+	}
+
+	path := []Point{destination}
+	for node := previous[1]; node > 0; node = previous[node] {
+		path = append([]Point{projection.toPoint(nodes[node])}, path...)
+	}
+	return append([]Point{source}, path...), true
+}
+
+// projectRing projects a polygon on the plane, without its closing vertex
+func projectRing(projection localProjection, polygon []Point) []vec {
+	ring := make([]vec, 0, len(polygon))
+	for _, p := range polygon {
+		ring = append(ring, projection.toVec(p))
+	}
+	if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+		ring = ring[:len(ring)-1]
+	}
+	return ring
+}
+
+// signedArea is positive for counterclockwise rings
+func signedArea(ring []vec) float64 {
+	area := 0.0
+	for i := range ring {
+		area += ring[i].cross(ring[(i+1)%len(ring)])
+	}
+	return area / 2
+}
+
+// bufferedCorners returns the convex corners of the ring pushed outward by the buffer distance.
+// Shortest paths around polygons only bend on convex corners, reflex ones are skipped.
+func bufferedCorners(ring []vec, buffer float64) []vec {
+	ccw := signedArea(ring) > 0
+	outward := func(edge vec) vec {
+		normal := vec{edge.Y, -edge.X}
+		if !ccw {
+			normal = normal.scale(-1)
+		}
+		return normal.scale(1 / normal.length())
+	}
+
+	corners := make([]vec, 0, len(ring))
+	for i, current := range ring {
+		prev := ring[(i+len(ring)-1)%len(ring)]
+		next := ring[(i+1)%len(ring)]
+		in, out := current.sub(prev), next.sub(current)
+		if in.length() == 0 || out.length() == 0 {
+			continue
+		}
+		turn := in.cross(out)
+		if (ccw && turn <= 0) || (!ccw && turn >= 0) {
+			continue
+		}
+		n1, n2 := outward(in), outward(out)
+		bisector := n1.add(n2)
+		bisector = bisector.scale(1 / bisector.length())
+		// Keep the buffer distance from both edges, bounded on sharp corners
+		offset := buffer / math.Max(bisector.dot(n1), 0.25)
+		corners = append(corners, current.add(bisector.scale(offset)))
+	}
+	return corners
+}
+
+// isVecInRing tells whether the point is inside the ring, by ray casting
+func isVecInRing(p vec, ring []vec) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func isVecInObstacles(p vec, obstacles [][]vec) bool {
+	for _, ring := range obstacles {
+		if isVecInRing(p, ring) {
+			return true
+		}
+	}
+	return false
+}
+
+// isVisible tells whether the segment between two nodes stays out of every obstacle
+func isVisible(a, b vec, obstacles [][]vec) bool {
+	middle := a.add(b).scale(0.5)
+	for _, ring := range obstacles {
+		if isVecInRing(middle, ring) {
+			return false
+		}
+		for i := range ring {
+			if segmentsCross(a, b, ring[i], ring[(i+1)%len(ring)]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// segmentsCross tells whether the segments properly cross each other, touching ends do not count
+func segmentsCross(p1, p2, q1, q2 vec) bool {
+	d1 := p2.sub(p1).cross(q1.sub(p1))
+	d2 := p2.sub(p1).cross(q2.sub(p1))
+	d3 := q2.sub(q1).cross(p1.sub(q1))
+	d4 := q2.sub(q1).cross(p2.sub(q1))
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
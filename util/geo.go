@@ -0,0 +1,85 @@
+package util
+
+import "math"
+
+// EarthRadius is the mean Earth radius in meters
+const EarthRadius = 6371e3
+
+// FeetToMeters converts feet to meters
+const FeetToMeters = 0.3048
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func toDegrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// DistanceMeters returns the great-circle distance between two points in meters
+func DistanceMeters(p1, p2 Point) float64 {
+	return haversineDistance(p1, p2)
+}
+
+// InitialBearing returns the initial great-circle bearing from p1 to p2 in degrees, in [0, 360)
+func InitialBearing(p1, p2 Point) float64 {
+	phi1 := toRadians(p1.Lat)
+	phi2 := toRadians(p2.Lat)
+	dLambda := toRadians(p2.Lon - p1.Lon)
+
+	y := math.Sin(dLambda) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLambda)
+	return math.Mod(toDegrees(math.Atan2(y, x))+360, 360)
+}
+
+// DestinationPoint returns the point reached from p after travelling the distance in meters
+// along the great circle starting with the given bearing in degrees
+func DestinationPoint(p Point, bearing float64, distance float64) Point {
+	delta := distance / EarthRadius
+	theta := toRadians(bearing)
+	phi1 := toRadians(p.Lat)
+	lambda1 := toRadians(p.Lon)
+
+	phi2 := math.Asin(math.Sin(phi1)*math.Cos(delta) + math.Cos(phi1)*math.Sin(delta)*math.Cos(theta))
+	lambda2 := lambda1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(phi1), math.Cos(delta)-math.Sin(phi1)*math.Sin(phi2))
+
+	return Point{Lat: toDegrees(phi2), Lon: math.Mod(toDegrees(lambda2)+540, 360) - 180}
+}
+
+// vec is a planar vector in meters, X toward the east and Y toward the north
+type vec struct {
+	X, Y float64
+}
+
+func (v vec) add(w vec) vec          { return vec{v.X + w.X, v.Y + w.Y} }
+func (v vec) sub(w vec) vec          { return vec{v.X - w.X, v.Y - w.Y} }
+func (v vec) scale(f float64) vec    { return vec{v.X * f, v.Y * f} }
+func (v vec) dot(w vec) float64      { return v.X*w.X + v.Y*w.Y }
+func (v vec) cross(w vec) float64    { return v.X*w.Y - v.Y*w.X }
+func (v vec) length() float64        { return math.Hypot(v.X, v.Y) }
+func (v vec) distance(w vec) float64 { return v.sub(w).length() }
+
+// localProjection is an equirectangular projection centered on an origin, accurate to a few
+// meters over the tens of kilometers covered by a route
+type localProjection struct {
+	origin Point
+	cosLat float64
+}
+
+func newLocalProjection(origin Point) localProjection {
+	return localProjection{origin: origin, cosLat: math.Cos(toRadians(origin.Lat))}
+}
+
+func (p localProjection) toVec(pt Point) vec {
+	return vec{
+		X: toRadians(pt.Lon-p.origin.Lon) * EarthRadius * p.cosLat,
+		Y: toRadians(pt.Lat-p.origin.Lat) * EarthRadius,
+	}
+}
+
+func (p localProjection) toPoint(v vec) Point {
+	return Point{
+		Lat: p.origin.Lat + toDegrees(v.Y/EarthRadius),
+		Lon: p.origin.Lon + toDegrees(v.X/(EarthRadius*p.cosLat)),
+	}
+}
@@ -0,0 +1,149 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// circleSegments is the number of edges of the polygon approximating a circle
+const circleSegments = 64
+
+const (
+	GeometryPoint        = "Point"
+	GeometryPolygon      = "Polygon"
+	GeometryMultiPolygon = "MultiPolygon"
+)
+
+// ParseGeometry reads a GeoJSON geometry into the polygons and circles of a zone.
+// Polygon and MultiPolygon keep their holes, a Point is the center of a circle of the given radius in meters.
+func ParseGeometry(geometryType string, coordinates interface{}, radius float64) ([]Polygon, []Circle, error) {
+	raw, err := json.Marshal(coordinates)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch geometryType {
+	case GeometryPoint:
+		var position []float64
+		if err := json.Unmarshal(raw, &position); err != nil {
+			return nil, nil, fmt.Errorf("invalid Point coordinates: %w", err)
+		}
+		center, err := toPoint(position)
+		if err != nil {
+			return nil, nil, err
+		}
+		if radius <= 0 {
+			return nil, nil, fmt.Errorf("a Point zone needs a positive radius")
+		}
+		return nil, []Circle{{Center: center, Radius: radius}}, nil
+	case GeometryPolygon:
+		var rings [][][]float64
+		if err := json.Unmarshal(raw, &rings); err != nil {
+			return nil, nil, fmt.Errorf("invalid Polygon coordinates: %w", err)
+		}
+		polygon, err := toPolygon(rings)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []Polygon{polygon}, nil, nil
+	case GeometryMultiPolygon:
+		var parts [][][][]float64
+		if err := json.Unmarshal(raw, &parts); err != nil {
+			return nil, nil, fmt.Errorf("invalid MultiPolygon coordinates: %w", err)
+		}
+		polygons := make([]Polygon, 0, len(parts))
+		for _, rings := range parts {
+			polygon, err := toPolygon(rings)
+			if err != nil {
+				return nil, nil, err
+			}
+			polygons = append(polygons, polygon)
+		}
+		return polygons, nil, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported geometry type: %s", geometryType)
+}
+
+// toPoint converts a GeoJSON [lon, lat] position
+func toPoint(position []float64) (Point, error) {
+	if len(position) < 2 {
+		return Point{}, fmt.Errorf("a position needs a longitude and a latitude")
+	}
+	return Point{Lat: position[1], Lon: position[0]}, nil
+}
+
+// toPolygon converts GeoJSON rings, the first one is the outer ring and the others are holes
+func toPolygon(rings [][][]float64) (Polygon, error) {
+	if len(rings) == 0 {
+		return Polygon{}, fmt.Errorf("a polygon needs an outer ring")
+	}
+	polygon := Polygon{}
+	for i, positions := range rings {
+		ring := make([]Point, 0, len(positions))
+		for _, position := range positions {
+			p, err := toPoint(position)
+			if err != nil {
+				return Polygon{}, err
+			}
+			ring = append(ring, p)
+		}
+		if len(ring) < 3 {
+			return Polygon{}, fmt.Errorf("a ring needs at least 3 positions")
+		}
+		if i == 0 {
+			polygon.Outer = ring
+		} else {
+			polygon.Holes = append(polygon.Holes, ring)
+		}
+	}
+	return polygon, nil
+}
+
+// Contains tells whether the point is inside the outer ring and outside the holes
+func (p Polygon) Contains(pt Point) bool {
+	if !isPointInPolygon(pt, p.Outer) {
+		return false
+	}
+	for _, hole := range p.Holes {
+		if isPointInPolygon(pt, hole) {
+			return false
+		}
+	}
+	return true
+}
+
+// ToPolygon approximates the circle by a regular polygon circumscribing it
+func (c Circle) ToPolygon() Polygon {
+	radius := c.Radius / math.Cos(math.Pi/circleSegments)
+	outer := make([]Point, 0, circleSegments+1)
+	for i := 0; i < circleSegments; i++ {
+		outer = append(outer, DestinationPoint(c.Center, float64(i)*360/circleSegments, radius))
+	}
+	return Polygon{Outer: append(outer, outer[0])}
+}
+
+// Areas returns the polygons of the zone, circles being approximated by polygons
+func (z RestrictedZone) Areas() []Polygon {
+	areas := make([]Polygon, 0, len(z.Polygons)+len(z.Circles))
+	areas = append(areas, z.Polygons...)
+	for _, circle := range z.Circles {
+		areas = append(areas, circle.ToPolygon())
+	}
+	return areas
+}
+
+// Contains tells whether the point is inside the zone
+func (z RestrictedZone) Contains(pt Point) bool {
+	for _, polygon := range z.Polygons {
+		if polygon.Contains(pt) {
+			return true
+		}
+	}
+	for _, circle := range z.Circles {
+		if DistanceMeters(circle.Center, pt) <= circle.Radius {
+			return true
+		}
+	}
+	return false
+}
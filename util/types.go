@@ -1,5 +1,7 @@
 package util
 
+import "time"
+
 // Point represents a latitude/longitude coordinate.
 type Point struct {
 	Lat, Lon float64
@@ -10,10 +12,37 @@ type Line struct {
 	Start, End Point
 }
 
-// RestrictedZone represents a polygon with a time range when it is active.
+// Polygon represents an area bounded by an outer ring, minus its holes.
+type Polygon struct {
+	Outer []Point
+	Holes [][]Point
+}
+
+// Circle represents a disc around a center, with a radius in meters.
+type Circle struct {
+	Center Point
+	Radius float64
+}
+
+// RestrictedZone represents an area made of polygons and circles with a time range when it is active.
+// The policy tells how resources in flight deal with the zone, empty for the default policy.
 type RestrictedZone struct {
 	ID        string
-	Polygon   []Point
+	Polygons  []Polygon
+	Circles   []Circle
 	StartTime int64
 	EndTime   int64
+	Policy    string
+}
+
+// IsActive tells whether the zone is active at some time of the interval.
+// The activation times are timestamps in milliseconds, 0 leaves the range open.
+func (z RestrictedZone) IsActive(from, to time.Time) bool {
+	if z.StartTime != 0 && to.Before(time.UnixMilli(z.StartTime)) {
+		return false
+	}
+	if z.EndTime != 0 && from.After(time.UnixMilli(z.EndTime)) {
+		return false
+	}
+	return true
 }
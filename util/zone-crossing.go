@@ -0,0 +1,161 @@
+package util
+
+import (
+	"math"
+	"sort"
+)
+
+// crossingEpsilon is the tolerance on the position along a segment, as a fraction of its length
+const crossingEpsilon = 1e-9
+
+// ZoneCrossing is a part of a path inside a zone, the distances are in meters from the start of the path
+type ZoneCrossing struct {
+	Entry         Point
+	Exit          Point
+	EntryDistance float64
+	ExitDistance  float64
+}
+
+// projectedPolygon and projectedCircle are the areas of a zone projected on the plane
+type projectedPolygon struct {
+	outer []vec
+	holes [][]vec
+}
+
+type projectedCircle struct {
+	center vec
+	radius float64
+}
+
+func (p projectedPolygon) contains(v vec) bool {
+	if !isVecInRing(v, p.outer) {
+		return false
+	}
+	for _, hole := range p.holes {
+		if isVecInRing(v, hole) {
+			return false
+		}
+	}
+	return true
+}
+
+// FindZoneCrossings returns every part of the great-circle segment inside the zone, in order.
+// The geometry is computed in a local projection centered on the segment. Concave polygons and holes may give
+// several crossings, a crossing starting at distance 0 means that the source is inside the zone and one ending
+// at the length of the segment that the destination is.
+func FindZoneCrossings(source, destination Point, zone RestrictedZone) []ZoneCrossing {
+	length := DistanceMeters(source, destination)
+	bearing := InitialBearing(source, destination)
+	projection := newLocalProjection(DestinationPoint(source, bearing, length/2))
+	a, b := projection.toVec(source), projection.toVec(destination)
+
+	polygons := make([]projectedPolygon, 0, len(zone.Polygons))
+	for _, polygon := range zone.Polygons {
+		projected := projectedPolygon{outer: projectRing(projection, polygon.Outer)}
+		for _, hole := range polygon.Holes {
+			projected.holes = append(projected.holes, projectRing(projection, hole))
+		}
+		polygons = append(polygons, projected)
+	}
+	circles := make([]projectedCircle, 0, len(zone.Circles))
+	for _, circle := range zone.Circles {
+		circles = append(circles, projectedCircle{center: projection.toVec(circle.Center), radius: circle.Radius})
+	}
+
+	// Positions along the segment where it crosses a boundary of the zone
+	positions := []float64{0, 1}
+	for _, polygon := range polygons {
+		for _, ring := range append([][]vec{polygon.outer}, polygon.holes...) {
+			for i := range ring {
+				if t, found := segmentIntersection(a, b, ring[i], ring[(i+1)%len(ring)]); found {
+					positions = append(positions, t)
+				}
+			}
+		}
+	}
+	for _, circle := range circles {
+		positions = append(positions, segmentCircleIntersections(a, b, circle)...)
+	}
+	sort.Float64s(positions)
+
+	// The parts between two boundaries are either fully inside or fully outside the zone
+	inside := func(t float64) bool {
+		v := a.add(b.sub(a).scale(t))
+		for _, polygon := range polygons {
+			if polygon.contains(v) {
+				return true
+			}
+		}
+		for _, circle := range circles {
+			if v.distance(circle.center) <= circle.radius {
+				return true
+			}
+		}
+		return false
+	}
+	pointAt := func(t float64) Point {
+		switch t {
+		case 0:
+			return source
+		case 1:
+			return destination
+		}
+		return DestinationPoint(source, bearing, t*length)
+	}
+
+	crossings := make([]ZoneCrossing, 0)
+	entry := -1.0
+	for i := 1; i < len(positions); i++ {
+		from, to := positions[i-1], positions[i]
+		if to-from < crossingEpsilon {
+			continue
+		}
+		if inside((from + to) / 2) {
+			if entry < 0 {
+				entry = from
+			}
+			continue
+		}
+		if entry >= 0 {
+			crossings = append(crossings, ZoneCrossing{Entry: pointAt(entry), Exit: pointAt(from), EntryDistance: entry * length, ExitDistance: from * length})
+			entry = -1
+		}
+	}
+	if entry >= 0 {
+		crossings = append(crossings, ZoneCrossing{Entry: pointAt(entry), Exit: destination, EntryDistance: entry * length, ExitDistance: length})
+	}
+	return crossings
+}
+
+// segmentIntersection returns the position along the segment ab where it meets the segment pq
+func segmentIntersection(a, b, p, q vec) (float64, bool) {
+	d, e := b.sub(a), q.sub(p)
+	denominator := d.cross(e)
+	if math.Abs(denominator) < crossingEpsilon {
+		// Parallel segments, overlaps are found by the inside test
+		return 0, false
+	}
+	t := p.sub(a).cross(e) / denominator
+	u := p.sub(a).cross(d) / denominator
+	if t < 0 || t > 1 || u < 0 || u > 1 {
+		return 0, false
+	}
+	return t, true
+}
+
+// segmentCircleIntersections returns the positions along the segment ab where it meets the circle
+func segmentCircleIntersections(a, b vec, circle projectedCircle) []float64 {
+	d, f := b.sub(a), a.sub(circle.center)
+	qa, qb, qc := d.dot(d), 2*f.dot(d), f.dot(f)-circle.radius*circle.radius
+	discriminant := qb*qb - 4*qa*qc
+	if qa == 0 || discriminant < 0 {
+		return nil
+	}
+	positions := make([]float64, 0, 2)
+	for _, t := range []float64{(-qb - math.Sqrt(discriminant)) / (2 * qa), (-qb + math.Sqrt(discriminant)) / (2 * qa)} {
+		if t >= 0 && t <= 1 {
+			positions = append(positions, t)
+		}
+	}
+	return positions
+}
@@ -0,0 +1,74 @@
+package util
+
+import (
+	"math"
+	"testing"
+)
+
+// square returns a ring around the equator between two longitudes, halfHeight degrees north and south
+func square(west, east, halfHeight float64) []Point {
+	return []Point{{Lat: -halfHeight, Lon: west}, {Lat: -halfHeight, Lon: east}, {Lat: halfHeight, Lon: east}, {Lat: halfHeight, Lon: west}}
+}
+
+func TestFindZoneCrossings(t *testing.T) {
+	west, east := Point{Lat: 0, Lon: -0.01}, Point{Lat: 0, Lon: 0.01}
+	tests := []struct {
+		name        string
+		source      Point
+		destination Point
+		zone        RestrictedZone
+		// want are the entry and exit longitudes of each crossing, along the equator
+		want [][2]float64
+	}{
+		{
+			name:        "through a hole",
+			source:      west,
+			destination: east,
+			zone:        RestrictedZone{Polygons: []Polygon{{Outer: square(-0.005, 0.005, 0.005), Holes: [][]Point{square(-0.002, 0.002, 0.002)}}}},
+			want:        [][2]float64{{-0.005, -0.002}, {0.002, 0.005}},
+		},
+		{
+			name:        "through a multipolygon",
+			source:      west,
+			destination: east,
+			zone:        RestrictedZone{Polygons: []Polygon{{Outer: square(-0.008, -0.004, 0.005)}, {Outer: square(0.003, 0.006, 0.005)}}},
+			want:        [][2]float64{{-0.008, -0.004}, {0.003, 0.006}},
+		},
+		{
+			name:        "from inside",
+			source:      Point{Lat: 0, Lon: 0},
+			destination: east,
+			zone:        RestrictedZone{Polygons: []Polygon{{Outer: square(-0.005, 0.005, 0.005)}}},
+			want:        [][2]float64{{0, 0.005}},
+		},
+		{
+			name:        "missing the zone",
+			source:      west,
+			destination: east,
+			zone:        RestrictedZone{Polygons: []Polygon{{Outer: []Point{{Lat: 0.002, Lon: -0.005}, {Lat: 0.002, Lon: 0.005}, {Lat: 0.005, Lon: 0.005}, {Lat: 0.005, Lon: -0.005}}}}},
+			want:        nil,
+		},
+	}
+
+	// Tolerance of 1 meter on the distances
+	const tolerance = 1.0
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crossings := FindZoneCrossings(tt.source, tt.destination, tt.zone)
+			if len(crossings) != len(tt.want) {
+				t.Fatalf("got %d crossings %+v, want %d", len(crossings), crossings, len(tt.want))
+			}
+			for i, want := range tt.want {
+				wantEntry := DistanceMeters(tt.source, Point{Lat: 0, Lon: want[0]})
+				wantExit := DistanceMeters(tt.source, Point{Lat: 0, Lon: want[1]})
+				if math.Abs(crossings[i].EntryDistance-wantEntry) > tolerance || math.Abs(crossings[i].ExitDistance-wantExit) > tolerance {
+					t.Errorf("crossing %d from %.1f m to %.1f m, want %.1f m to %.1f m",
+						i, crossings[i].EntryDistance, crossings[i].ExitDistance, wantEntry, wantExit)
+				}
+				if math.Abs(crossings[i].Entry.Lon-want[0]) > 1e-5 || math.Abs(crossings[i].Exit.Lon-want[1]) > 1e-5 {
+					t.Errorf("crossing %d from %+v to %+v, want longitudes %g to %g", i, crossings[i].Entry, crossings[i].Exit, want[0], want[1])
+				}
+			}
+		})
+	}
+}